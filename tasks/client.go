@@ -0,0 +1,10 @@
+// Package tasks bundles the browser client for the tasks hypermedia application so that
+// servers written in go can embed it.
+package tasks
+
+import "embed"
+
+// Client holds the browser client's assets, index.html and tasks.js.
+//
+//go:embed index.html tasks.js
+var Client embed.FS
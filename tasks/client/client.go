@@ -0,0 +1,355 @@
+// Package client navigates Uber hypermedia APIs. A client starts from an entry URL and
+// reaches everything else by following the transitions, found by id or rel, advertised in
+// the documents it is sent, so it does not depend on the server's URL layout.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/uber-apps/tasks/uber"
+)
+
+// Media types the client understands.
+const (
+	uberType = "application/vnd.uber+json"
+	jsonType = "application/json"
+	formType = "application/x-www-form-urlencoded"
+)
+
+// Client performs the transitions of an Uber hypermedia API.
+type Client struct {
+	// HTTPClient makes the client's requests. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	entry *url.URL
+}
+
+// New creates a client for the API whose entry point is at entry.
+func New(entry string) (*Client, error) {
+	u, err := url.Parse(entry)
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid entry url %q: %v", entry, err)
+	}
+	if !u.IsAbs() {
+		return nil, fmt.Errorf("client: entry url %q is not absolute", entry)
+	}
+	return &Client{entry: u}, nil
+}
+
+// Entry reads the API's entry point.
+func (c *Client) Entry() (*Resource, error) {
+	return c.do("GET", c.entry, "", nil)
+}
+
+// Resource is an Uber document along with the URL it was read from. Relative URLs in the
+// document are resolved against that URL.
+type Resource struct {
+	URL *url.URL
+	Doc *uber.Doc
+
+	client *Client
+	scope  *uber.Data
+}
+
+// NoTransitionError is returned when a resource does not advertise a requested transition.
+type NoTransitionError struct {
+	Name string
+	URL  string
+}
+
+func (e *NoTransitionError) Error() string {
+	return fmt.Sprintf("client: %s does not advertise a %q transition", e.URL, e.Name)
+}
+
+// Within returns a view of the resource limited to the data element with the given id, e.g.
+// a single item of a list, so that its transitions can be told apart from those of its
+// siblings.
+func (r *Resource) Within(id string) (*Resource, error) {
+	d := r.find(func(d *uber.Data) bool { return d.ID == id })
+	if d == nil {
+		return nil, fmt.Errorf("client: %s has no element with id %q", r.URL, id)
+	}
+	return &Resource{URL: r.URL, Doc: r.Doc, client: r.client, scope: d}, nil
+}
+
+// Transition returns the transition named by name. An element whose id is name is preferred
+// to the first whose rel includes name.
+func (r *Resource) Transition(name string) (*Transition, error) {
+	isLink := func(d *uber.Data) bool { return len(d.URL) > 0 }
+
+	d := r.find(func(d *uber.Data) bool { return isLink(d) && d.ID == name })
+	if d == nil {
+		d = r.find(func(d *uber.Data) bool { return isLink(d) && d.HasRel(name) })
+	}
+	if d == nil {
+		return nil, &NoTransitionError{Name: name, URL: r.URL.String()}
+	}
+	return &Transition{Data: d, resource: r}, nil
+}
+
+// Transitions returns every transition within the resource's scope in document order.
+func (r *Resource) Transitions() []*Transition {
+	ts := []*Transition{}
+	r.find(func(d *uber.Data) bool {
+		if len(d.URL) > 0 {
+			ts = append(ts, &Transition{Data: d, resource: r})
+		}
+		return false
+	})
+	return ts
+}
+
+// Follow performs the transition named by name with the given values. It is shorthand for
+// calling Transition and then Do.
+func (r *Resource) Follow(name string, values map[string]string) (*Resource, error) {
+	t, err := r.Transition(name)
+	if err != nil {
+		return nil, err
+	}
+	return t.Do(values)
+}
+
+// find returns the first element within the resource's scope that satisfies match.
+func (r *Resource) find(match func(*uber.Data) bool) *uber.Data {
+	if r.Doc == nil {
+		return nil
+	}
+
+	var found *uber.Data
+	visit := func(_ string, d *uber.Data) error {
+		if found == nil && match(d) {
+			found = d
+		}
+		return nil
+	}
+
+	if r.scope != nil {
+		visit("", r.scope)
+		r.scope.Walk(visit)
+	} else {
+		r.Doc.Walk(visit)
+	}
+	return found
+}
+
+// Transition is a hypermedia control advertised by a resource.
+type Transition struct {
+	Data *uber.Data
+
+	resource *Resource
+}
+
+// Method returns the HTTP method that performs the transition.
+func (t *Transition) Method() string {
+	return uber.Method(t.Data.Action)
+}
+
+// URL returns the URL the transition targets once its template, if any, is filled in from
+// values.
+func (t *Transition) URL(values map[string]string) (*url.URL, error) {
+	target := t.Data.URL
+	if t.Data.Template {
+		tpl, err := uber.ParseTemplate(target)
+		if err != nil {
+			return nil, fmt.Errorf("client: invalid url template %q: %v", target, err)
+		}
+		target = tpl.Expand(values)
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid url %q: %v", target, err)
+	}
+	return t.resource.URL.ResolveReference(u), nil
+}
+
+// Name returns the name the transition is known by, its id or else its first rel.
+func (t *Transition) Name() string {
+	if len(t.Data.ID) > 0 || len(t.Data.Rel) == 0 {
+		return t.Data.ID
+	}
+	return t.Data.Rel[0]
+}
+
+// Params returns the names of the values the transition needs, the variables of its URL
+// template followed by the {name} placeholders of its model.
+func (t *Transition) Params() []string {
+	params := []string{}
+	if t.Data.Template {
+		if tpl, err := uber.ParseTemplate(t.Data.URL); err == nil {
+			params = append(params, tpl.Variables()...)
+		}
+	}
+	for _, arg := range strings.Split(strings.TrimPrefix(t.Data.Model, "?"), "&") {
+		kv := strings.SplitN(arg, "=", 2)
+		switch {
+		case len(kv[0]) == 0:
+		case len(kv) == 1:
+			params = append(params, kv[0])
+		case strings.HasPrefix(kv[1], "{") && strings.HasSuffix(kv[1], "}"):
+			params = append(params, strings.Trim(kv[1], "{}"))
+		}
+	}
+	return params
+}
+
+// Args returns the arguments the transition's model describes. Arguments whose value is a
+// {name} placeholder are taken from values, and omitted if values has no entry for them.
+func (t *Transition) Args(values map[string]string) url.Values {
+	args := url.Values{}
+	for _, arg := range strings.Split(strings.TrimPrefix(t.Data.Model, "?"), "&") {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv[0]) == 0 {
+			continue
+		}
+
+		switch {
+		case len(kv) == 1:
+			if v, ok := values[kv[0]]; ok {
+				args.Set(kv[0], v)
+			}
+		case strings.HasPrefix(kv[1], "{") && strings.HasSuffix(kv[1], "}"):
+			if v, ok := values[strings.Trim(kv[1], "{}")]; ok {
+				args.Set(kv[0], v)
+			}
+		default:
+			args.Set(kv[0], kv[1])
+		}
+	}
+	return args
+}
+
+// Do performs the transition and returns the resource the server responds with. The
+// arguments of the model are sent in the request body, in the first of the transition's
+// sending media types the client can produce, or in the query string of a read. A response
+// without a body yields a resource with a nil Doc. If the server reports an error, Do
+// returns it as an *uber.Error.
+func (t *Transition) Do(values map[string]string) (*Resource, error) {
+	u, err := t.URL(values)
+	if err != nil {
+		return nil, err
+	}
+
+	method := t.Method()
+	args := t.Args(values)
+
+	if method == "GET" || method == "DELETE" {
+		if len(args) > 0 {
+			q := u.Query()
+			for k, vs := range args {
+				q[k] = vs
+			}
+			u.RawQuery = q.Encode()
+		}
+		return t.resource.client.do(method, u, "", nil)
+	}
+
+	ct, body, err := encodeArgs(t.Data.Sending, args)
+	if err != nil {
+		return nil, err
+	}
+	return t.resource.client.do(method, u, ct, body)
+}
+
+// encodeArgs encodes args in the first media type listed in sending, a space separated list,
+// that the client can produce. An empty list means a form.
+func encodeArgs(sending string, args url.Values) (string, []byte, error) {
+	types := strings.Fields(sending)
+	if len(types) == 0 {
+		types = []string{formType}
+	}
+
+	for _, mt := range types {
+		switch mt {
+		case formType:
+			return formType, []byte(args.Encode()), nil
+
+		case jsonType:
+			obj := map[string]string{}
+			for k := range args {
+				obj[k] = args.Get(k)
+			}
+			b, err := json.Marshal(obj)
+			return jsonType, b, err
+
+		case uberType:
+			names := []string{}
+			for k := range args {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+
+			ds := []*uber.DataBuilder{}
+			for _, k := range names {
+				ds = append(ds, uber.NewData().Name(k).Value(args.Get(k)))
+			}
+			b, err := json.Marshal(uber.NewDoc().Data(ds...).Build())
+			return uberType, b, err
+		}
+	}
+
+	return "", nil, fmt.Errorf("client: cannot produce any of %s", sending)
+}
+
+// do makes a request and decodes the Uber document the server responds with.
+func (c *Client) do(method string, u *url.URL, contentType string, body []byte) (*Resource, error) {
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", uberType)
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("client: cannot read response from %s: %v", u, err)
+	}
+
+	var ud *uber.Doc
+	if mt, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && (mt == uberType || mt == jsonType) && len(b) > 0 {
+		ud, err = uber.Parse(bytes.NewReader(b))
+		if err != nil && resp.StatusCode < 300 {
+			return nil, fmt.Errorf("client: invalid response from %s: %v", u, err)
+		}
+	}
+
+	if resp.StatusCode >= 300 {
+		var e *uber.Error
+		if ud != nil {
+			e = uber.ErrorFromDoc(ud)
+		}
+		if e == nil {
+			e = &uber.Error{Code: "http_error", Message: http.StatusText(resp.StatusCode)}
+		}
+		if e.Status == 0 {
+			e.Status = resp.StatusCode
+		}
+		return nil, e
+	}
+
+	if resp.Request != nil && resp.Request.URL != nil {
+		u = resp.Request.URL
+	}
+	return &Resource{URL: u, Doc: ud, client: c}, nil
+}
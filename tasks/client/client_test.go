@@ -0,0 +1,205 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/uber-apps/tasks/uber"
+)
+
+// request records what the test server was sent.
+type request struct {
+	method      string
+	path        string
+	query       string
+	contentType string
+	body        string
+}
+
+// testServer serves an API shaped like taskd's but at different URLs, so the tests show the
+// client only uses the URLs it is given.
+func testServer(t *testing.T, got *request) *httptest.Server {
+	links := uber.NewData().ID("links").Append(
+		uber.NewData().ID("list").Rel("collection").URL("/v2/items").Action(uber.ActionRead),
+		uber.NewData().ID("search").Rel("search").URL("/v2/items/find{?q}").Template(true).Action(uber.ActionRead),
+		uber.NewData().ID("add").Rel("add").URL("/v2/items").Action(uber.ActionAppend).Model("text={text}").
+			Sending("application/json application/x-www-form-urlencoded"))
+	items := uber.NewData().ID("tasks").Append(
+		uber.NewData().ID("task1").Append(
+			uber.NewData().Rel("complete").URL("/v2/done").Action(uber.ActionAppend).Model("id=task1"),
+			uber.NewData().Name("text").Value("task one")),
+		uber.NewData().ID("task2").Append(
+			uber.NewData().Rel("complete").URL("/v2/done").Action(uber.ActionAppend).Model("id=task2"),
+			uber.NewData().Name("text").Value("task two")))
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		*got = request{req.Method, req.URL.Path, req.URL.RawQuery, req.Header.Get("Content-Type"), string(body)}
+
+		var ud *uber.Doc
+		status := http.StatusOK
+		switch {
+		case req.URL.Path == "/v2/items" && req.Method == "POST":
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case req.URL.Path == "/v2/done" && string(body) == "id=task2":
+			status, ud = http.StatusNotFound, (&uber.Error{Code: "task_not_found", Status: http.StatusNotFound, Message: "No such task"}).Doc()
+		case req.URL.Path == "/v2/done":
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case req.URL.Path == "/":
+			ud = uber.NewDoc().Data(links).Build()
+		case req.URL.Path == "/v2/items" || req.URL.Path == "/v2/items/find":
+			ud = uber.NewDoc().Data(links, items).Build()
+		default:
+			http.NotFound(w, req)
+			return
+		}
+
+		w.Header().Set("Content-Type", uberType)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ud)
+	}))
+}
+
+func TestNavigation(t *testing.T) {
+	var got request
+	ts := testServer(t, &got)
+	defer ts.Close()
+
+	c, err := New(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := c.Entry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tt := []struct {
+		description string
+		transition  string
+		values      map[string]string
+		expected    request
+	}{
+		{"follow by id", "list", nil, request{"GET", "/v2/items", "", "", ""}},
+		{"follow by rel", "collection", nil, request{"GET", "/v2/items", "", "", ""}},
+		{"expand template", "search", map[string]string{"q": "eggs & milk"}, request{"GET", "/v2/items/find", "q=eggs%20%26%20milk", "", ""}},
+		{"fill model", "add", map[string]string{"text": "buy eggs"}, request{"POST", "/v2/items", "", jsonType, `{"text":"buy eggs"}`}},
+	}
+
+	for _, tst := range tt {
+		if _, err := entry.Follow(tst.transition, tst.values); err != nil {
+			t.Errorf("%s: unexpected error %v", tst.description, err)
+			continue
+		}
+		if got != tst.expected {
+			t.Errorf("%s: expected request %+v, got %+v", tst.description, tst.expected, got)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	var got request
+	ts := testServer(t, &got)
+	defer ts.Close()
+
+	c, _ := New(ts.URL + "/")
+	entry, err := c.Entry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := entry.Follow("collection", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := list.Within("task1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := item.Follow("complete", nil); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if expected := (request{"POST", "/v2/done", "", formType, "id=task1"}); got != expected {
+		t.Errorf("expected request %+v, got %+v", expected, got)
+	}
+
+	if _, err := list.Within("task3"); err == nil {
+		t.Errorf("expected an error for a missing element")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var got request
+	ts := testServer(t, &got)
+	defer ts.Close()
+
+	c, _ := New(ts.URL + "/")
+	entry, err := c.Entry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := entry.Follow("delete", nil); err == nil {
+		t.Errorf("missing transition: expected an error")
+	} else if _, ok := err.(*NoTransitionError); !ok {
+		t.Errorf("missing transition: expected a *NoTransitionError, got %T", err)
+	}
+
+	list, _ := entry.Follow("list", nil)
+	item, _ := list.Within("task2")
+	_, err = item.Follow("complete", nil)
+	e, ok := err.(*uber.Error)
+	switch {
+	case !ok:
+		t.Errorf("server error: expected an *uber.Error, got %T %v", err, err)
+	case e.Code != "task_not_found" || e.Status != http.StatusNotFound:
+		t.Errorf("server error: expected task_not_found (404), got %v", e)
+	}
+
+	c, _ = New(ts.URL + "/nowhere")
+	_, err = c.Entry()
+	if e, ok := err.(*uber.Error); !ok || e.Status != http.StatusNotFound {
+		t.Errorf("plain error: expected an *uber.Error with status 404, got %v", err)
+	}
+
+	if _, err := New("/relative"); err == nil {
+		t.Errorf("relative entry: expected an error")
+	}
+}
+
+func TestTransitions(t *testing.T) {
+	var got request
+	ts := testServer(t, &got)
+	defer ts.Close()
+
+	c, _ := New(ts.URL + "/")
+	entry, err := c.Entry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, params := []string{}, []string{}
+	for _, tr := range entry.Transitions() {
+		names = append(names, tr.Name())
+		params = append(params, tr.Params()...)
+	}
+	if strings.Join(names, ",") != "list,search,add" {
+		t.Errorf("expected transitions list,search,add, got %v", names)
+	}
+	if strings.Join(params, ",") != "q,text" {
+		t.Errorf("expected params q,text, got %v", params)
+	}
+
+	list, _ := entry.Follow("list", nil)
+	item, _ := list.Within("task2")
+	if trs := item.Transitions(); len(trs) != 1 || trs[0].Name() != "complete" || len(trs[0].Params()) != 0 {
+		t.Errorf("expected only the complete transition without params, got %d transitions", len(trs))
+	}
+}
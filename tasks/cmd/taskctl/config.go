@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultURL is the entry point used when the configuration doesn't name one.
+const defaultURL = "http://localhost:3006/tasks"
+
+// config holds taskctl's settings. They are read from a dotfile of key = value lines, e.g.
+//
+//	# the task service
+//	url = https://tasks.example.com/tasks
+//	user = oncall
+//	password = secret
+//
+// Blank lines and lines starting with # are ignored. A token, if given, is sent as a bearer
+// token in preference to the user and password.
+type config struct {
+	url      string
+	user     string
+	password string
+	token    string
+}
+
+// defaultConfigPath returns the location of the dotfile, ~/.taskctl.
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".taskctl"
+	}
+	return filepath.Join(home, ".taskctl")
+}
+
+// loadConfig reads the dotfile at path. A missing dotfile yields the default configuration.
+func loadConfig(path string) (config, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return config{url: defaultURL}, nil
+	}
+	if err != nil {
+		return config{}, err
+	}
+	defer f.Close()
+
+	return parseConfig(f)
+}
+
+// parseConfig reads a configuration in dotfile format from r.
+func parseConfig(r io.Reader) (config, error) {
+	cfg := config{url: defaultURL}
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return config{}, fmt.Errorf("line %d: expected key = value", n)
+		}
+
+		value := strings.TrimSpace(kv[1])
+		switch key := strings.TrimSpace(kv[0]); key {
+		case "url":
+			cfg.url = value
+		case "user":
+			cfg.user = value
+		case "password":
+			cfg.password = value
+		case "token":
+			cfg.token = value
+		default:
+			return config{}, fmt.Errorf("line %d: unknown setting %q", n, key)
+		}
+	}
+
+	return cfg, s.Err()
+}
+
+// credentials is an http.RoundTripper that adds the configured credentials to each request
+// for the scheme and host of the entry point. Requests for links to other hosts, or to the
+// same host over another scheme, are sent without them.
+type credentials struct {
+	cfg  config
+	next http.RoundTripper
+}
+
+func (c credentials) RoundTrip(req *http.Request) (*http.Response, error) {
+	if entry, err := url.Parse(c.cfg.url); err != nil || req.URL.Scheme != entry.Scheme || req.URL.Host != entry.Host {
+		return c.next.RoundTrip(req)
+	}
+
+	switch {
+	case len(c.cfg.token) > 0:
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+c.cfg.token)
+	case len(c.cfg.user) > 0:
+		req = req.Clone(req.Context())
+		req.SetBasicAuth(c.cfg.user, c.cfg.password)
+	}
+	return c.next.RoundTrip(req)
+}
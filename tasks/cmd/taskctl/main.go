@@ -0,0 +1,230 @@
+// Command taskctl manages a task list from the terminal. It knows only the entry point of
+// the task service; every other endpoint is discovered from the links the service sends.
+//
+//	taskctl [-config file] [-url entry] [-o table|json|uber] command [arguments]
+//
+// The commands are
+//
+//	list                 show the tasks
+//	search text          show the tasks whose text matches
+//	add text...          add a task
+//	complete id          complete a task
+//	edit id text...      replace the text of a task
+//	ui                   browse and change the tasks interactively
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/uber-apps/tasks/client"
+	"github.com/uber-apps/tasks/uber"
+)
+
+// command is one of taskctl's subcommands. Run performs it starting from the entry
+// resource and returns the resource to display.
+type command struct {
+	usage string
+	args  int
+	run   func(entry *client.Resource, args []string) (*client.Resource, error)
+}
+
+var commands = map[string]command{
+	"list": {"list", 0, func(entry *client.Resource, args []string) (*client.Resource, error) {
+		return entry.Follow("collection", nil)
+	}},
+	"search": {"search text", 1, func(entry *client.Resource, args []string) (*client.Resource, error) {
+		return entry.Follow("search", map[string]string{"text": strings.Join(args, " ")})
+	}},
+	"add": {"add text...", 1, func(entry *client.Resource, args []string) (*client.Resource, error) {
+		if _, err := entry.Follow("add", map[string]string{"text": strings.Join(args, " ")}); err != nil {
+			return nil, err
+		}
+		return entry.Follow("collection", nil)
+	}},
+	"complete": {"complete id", 1, func(entry *client.Resource, args []string) (*client.Resource, error) {
+		return onItem(entry, args[0], "complete", nil)
+	}},
+	"edit": {"edit id text...", 2, func(entry *client.Resource, args []string) (*client.Resource, error) {
+		return onItem(entry, args[0], "edit", map[string]string{"text": strings.Join(args[1:], " ")})
+	}},
+}
+
+// onItem performs the named transition of the task with the given id and then shows the
+// task list.
+func onItem(entry *client.Resource, id, name string, values map[string]string) (*client.Resource, error) {
+	list, err := entry.Follow("collection", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := list.Within(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := item.Follow(name, values); err != nil {
+		return nil, err
+	}
+	return entry.Follow("collection", nil)
+}
+
+// formats maps the names accepted by -o to the functions that print a resource.
+var formats = map[string]func(io.Writer, *client.Resource) error{
+	"table": printTable,
+	"json":  printJSON,
+	"uber":  printUber,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run carries out the command line args and returns the exit status: 0 on success, 1 if the
+// command failed and 2 if it was used incorrectly.
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("taskctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cfgpath := fs.String("config", defaultConfigPath(), "read settings from `file`")
+	entry := fs.String("url", "", "use `url` as the entry point instead of the configured one")
+	format := fs.String("o", "table", "print results as `format`: table, json or uber")
+	refresh := fs.Duration("refresh", 5*time.Second, "with ui, reread the list every `interval` if the server has no change feed")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: taskctl [flags] command [arguments]\n\ncommands:")
+		for _, name := range []string{"list", "search", "add", "complete", "edit"} {
+			fmt.Fprintf(stderr, "  %s\n", commands[name].usage)
+		}
+		fmt.Fprintln(stderr, "  ui")
+		fmt.Fprintln(stderr, "\nflags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	interactive := fs.Arg(0) == "ui"
+	cmd, ok := commands[fs.Arg(0)]
+	if !interactive && (!ok || fs.NArg()-1 < cmd.args) {
+		fs.Usage()
+		return 2
+	}
+
+	show, ok := formats[*format]
+	if !ok {
+		fmt.Fprintf(stderr, "taskctl: unknown output format %q\n", *format)
+		return 2
+	}
+
+	cfg, err := loadConfig(*cfgpath)
+	if err != nil {
+		fmt.Fprintf(stderr, "taskctl: %s: %v\n", *cfgpath, err)
+		return 1
+	}
+	if len(*entry) > 0 {
+		cfg.url = *entry
+	}
+
+	c, err := client.New(cfg.url)
+	if err != nil {
+		fmt.Fprintf(stderr, "taskctl: %v\n", err)
+		return 1
+	}
+	c.HTTPClient = &http.Client{Transport: credentials{cfg: cfg, next: http.DefaultTransport}}
+
+	start, err := c.Entry()
+	if err != nil {
+		fmt.Fprintf(stderr, "taskctl: %v\n", err)
+		return 1
+	}
+
+	if interactive {
+		if err := runUI(start, c.HTTPClient, *refresh); err != nil {
+			fmt.Fprintf(stderr, "taskctl: %v\n", err)
+			return 1
+		}
+		return 0
+	}
+
+	res, err := cmd.run(start, fs.Args()[1:])
+	if err != nil {
+		fmt.Fprintf(stderr, "taskctl: %v\n", describe(err))
+		return 1
+	}
+
+	if err := show(stdout, res); err != nil {
+		fmt.Fprintf(stderr, "taskctl: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// describe adds the field errors the server reported to the error's message.
+func describe(err error) string {
+	e, ok := err.(*uber.Error)
+	if !ok || len(e.Fields) == 0 {
+		return err.Error()
+	}
+
+	msg := e.Error()
+	for _, f := range e.Fields {
+		msg += fmt.Sprintf("\n  %s: %s", f.Field, f.Message)
+	}
+	return msg
+}
+
+// item is a task as taskctl shows it.
+type item struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// items returns the tasks in the resource, the elements whose rel is item.
+func items(res *client.Resource) []item {
+	found := []item{}
+	if res.Doc == nil {
+		return found
+	}
+
+	res.Doc.Walk(func(_ string, d *uber.Data) error {
+		if !d.HasRel("item") {
+			return nil
+		}
+
+		it := item{ID: d.ID}
+		if texts := d.FindByName("text"); len(texts) > 0 {
+			it.Text = texts[0].Value
+		}
+		found = append(found, it)
+		return uber.SkipData
+	})
+	return found
+}
+
+func printTable(w io.Writer, res *client.Resource) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTEXT")
+	for _, it := range items(res) {
+		fmt.Fprintf(tw, "%s\t%s\n", it.ID, it.Text)
+	}
+	return tw.Flush()
+}
+
+func printJSON(w io.Writer, res *client.Resource) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(items(res))
+}
+
+func printUber(w io.Writer, res *client.Resource) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(res.Doc)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/uber-apps/tasks/uber"
+)
+
+// fakeServer serves a task list shaped like taskd's at URLs taskctl has never heard of.
+func fakeServer(tasks *[]string, auth *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		*auth = req.Header.Get("Authorization")
+		req.ParseForm()
+
+		switch req.URL.Path {
+		case "/api/new":
+			*tasks = append(*tasks, req.PostForm.Get("text"))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case "/api/change":
+			var i int
+			if _, err := fmt.Sscanf(req.PostForm.Get("id"), "task%d", &i); err != nil || i > len(*tasks) {
+				e := &uber.Error{Code: "task_not_found", Status: http.StatusNotFound, Message: "No such task"}
+				w.Header().Set("Content-Type", "application/vnd.uber+json")
+				w.WriteHeader(http.StatusNotFound)
+				json.NewEncoder(w).Encode(e.Doc())
+				return
+			}
+			(*tasks)[i-1] = req.PostForm.Get("text")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		list := uber.NewData().ID("tasks")
+		for i, t := range *tasks {
+			id := fmt.Sprintf("task%d", i+1)
+			if q := req.URL.Query().Get("text"); len(q) > 0 && !strings.Contains(t, q) {
+				continue
+			}
+			list.Append(uber.NewData().ID(id).Rel("item").Append(
+				uber.NewData().Rel("edit").URL("/api/change").Action(uber.ActionReplace).Model("id="+id+"&text={text}"),
+				uber.NewData().Name("text").Value(t)))
+		}
+		ud := uber.NewDoc().Data(
+			uber.NewData().ID("links").Append(
+				uber.NewData().ID("list").Rel("collection").URL("/api/all").Action(uber.ActionRead),
+				uber.NewData().ID("search").Rel("search").URL("/api/find{?text}").Template(true).Action(uber.ActionRead),
+				uber.NewData().ID("add").Rel("add").URL("/api/new").Action(uber.ActionAppend).Model("text={text}")),
+			list).Build()
+
+		w.Header().Set("Content-Type", "application/vnd.uber+json")
+		json.NewEncoder(w).Encode(ud)
+	}))
+}
+
+func TestRun(t *testing.T) {
+	tt := []struct {
+		description string
+		args        []string
+		status      int
+		stdout      string
+		tasks       []string
+	}{
+		{"list", []string{"list"}, 0, "ID     TEXT\ntask1  task one\ntask2  task two\n", []string{"task one", "task two"}},
+		{"list as json", []string{"-o", "json", "list"}, 0, "[\n  {\n    \"id\": \"task1\",\n    \"text\": \"task one\"\n  },\n  {\n    \"id\": \"task2\",\n    \"text\": \"task two\"\n  }\n]\n", []string{"task one", "task two"}},
+		{"search", []string{"search", "two"}, 0, "ID     TEXT\ntask2  task two\n", []string{"task one", "task two"}},
+		{"add", []string{"add", "buy", "eggs", "&", "milk"}, 0, "ID     TEXT\ntask1  task one\ntask2  task two\ntask3  buy eggs & milk\n", []string{"task one", "task two", "buy eggs & milk"}},
+		{"edit", []string{"edit", "task1", "task un"}, 0, "ID     TEXT\ntask1  task un\ntask2  task two\n", []string{"task un", "task two"}},
+		{"complete not advertised", []string{"complete", "task1"}, 1, "", []string{"task one", "task two"}},
+		{"edit missing task", []string{"edit", "task7", "task sept"}, 1, "", []string{"task one", "task two"}},
+		{"unknown command", []string{"purge"}, 2, "", []string{"task one", "task two"}},
+		{"missing argument", []string{"add"}, 2, "", []string{"task one", "task two"}},
+		{"unknown format", []string{"-o", "xml", "list"}, 2, "", []string{"task one", "task two"}},
+	}
+
+	for _, tst := range tt {
+		tasks, auth := []string{"task one", "task two"}, ""
+		ts := fakeServer(&tasks, &auth)
+
+		args := append([]string{"-config", filepath.Join(t.TempDir(), "missing"), "-url", ts.URL + "/"}, tst.args...)
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		status := run(args, stdout, stderr)
+		ts.Close()
+
+		if status != tst.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tst.description, tst.status, status, stderr)
+			continue
+		}
+		if status == 0 && stdout.String() != tst.stdout {
+			t.Errorf("%s: expected output\n%s\ngot\n%s", tst.description, tst.stdout, stdout)
+		}
+		if strings.Join(tasks, ",") != strings.Join(tst.tasks, ",") {
+			t.Errorf("%s: expected tasks %v, got %v", tst.description, tst.tasks, tasks)
+		}
+	}
+}
+
+// roundTripper records the Authorization header of the requests it is sent.
+type roundTripper []string
+
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	*rt = append(*rt, req.Header.Get("Authorization"))
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("")), Request: req}, nil
+}
+
+func TestCredentialsHost(t *testing.T) {
+	tt := []struct {
+		description string
+		url         string
+		auth        string
+	}{
+		{"entry point", "https://tasks.example.com/tasks", "Bearer abc123"},
+		{"same host", "https://tasks.example.com/tasks/search?text=x", "Bearer abc123"},
+		{"other host", "https://elsewhere.example.com/tasks", ""},
+		{"other port", "https://tasks.example.com:8443/tasks", ""},
+		{"downgrade to http", "http://tasks.example.com/tasks", ""},
+		{"host as user info", "https://tasks.example.com@elsewhere.example.com/tasks", ""},
+	}
+
+	for _, tst := range tt {
+		rt := &roundTripper{}
+		c := credentials{cfg: config{url: "https://tasks.example.com/tasks", token: "abc123"}, next: rt}
+
+		req, err := http.NewRequest("GET", tst.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.RoundTrip(req); err != nil {
+			t.Errorf("%s: %v", tst.description, err)
+			continue
+		}
+		if (*rt)[0] != tst.auth {
+			t.Errorf("%s: expected Authorization %q, got %q", tst.description, tst.auth, (*rt)[0])
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	tt := []struct {
+		description string
+		dotfile     string
+		auth        string
+	}{
+		{"no credentials", "# just the url\nurl = %s/\n", ""},
+		{"basic", "url = %s/\nuser = oncall\npassword = secret\n", "Basic b25jYWxsOnNlY3JldA=="},
+		{"token", "url = %s/\nuser = oncall\ntoken = abc123\n", "Bearer abc123"},
+	}
+
+	for _, tst := range tt {
+		tasks, auth := []string{}, ""
+		ts := fakeServer(&tasks, &auth)
+
+		path := filepath.Join(t.TempDir(), ".taskctl")
+		if err := ioutil.WriteFile(path, []byte(fmt.Sprintf(tst.dotfile, ts.URL)), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		stderr := &bytes.Buffer{}
+		status := run([]string{"-config", path, "list"}, ioutil.Discard, stderr)
+		ts.Close()
+
+		if status != 0 {
+			t.Errorf("%s: unexpected status %d: %s", tst.description, status, stderr)
+			continue
+		}
+		if auth != tst.auth {
+			t.Errorf("%s: expected Authorization %q, got %q", tst.description, tst.auth, auth)
+		}
+	}
+
+	if _, err := parseConfig(strings.NewReader("colour = blue\n")); err == nil {
+		t.Errorf("unknown setting: expected an error")
+	}
+	if _, err := parseConfig(strings.NewReader("url\n")); err == nil {
+		t.Errorf("malformed line: expected an error")
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ANSI escape sequences used to draw the terminal UI.
+const (
+	clearScreen = "\x1b[2J\x1b[H"
+	hideCursor  = "\x1b[?25l"
+	showCursor  = "\x1b[?25h"
+	reverse     = "\x1b[7m"
+	bold        = "\x1b[1m"
+	reset       = "\x1b[0m"
+)
+
+// Keys that don't stand for themselves. Printable characters are reported as their rune.
+const (
+	keyUp rune = -(iota + 1)
+	keyDown
+	keyEnter
+	keyEscape
+	keyBackspace
+	keyInterrupt
+)
+
+// terminal is the minimal terminal layer the UI needs: raw mode, its size and decoded key
+// presses. Raw mode is set with stty(1) so that no terminal library is required.
+type terminal struct {
+	tty   *os.File
+	saved string
+}
+
+// openTerminal puts the terminal attached to tty into raw mode. Restore must be called to
+// return it to its previous state.
+func openTerminal(tty *os.File) (*terminal, error) {
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		return nil, fmt.Errorf("not a terminal: %v", err)
+	}
+	if _, err := stty(tty, "raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return &terminal{tty: tty, saved: saved}, nil
+}
+
+// Restore returns the terminal to the state it was in before it was opened.
+func (t *terminal) Restore() error {
+	_, err := stty(t.tty, t.saved)
+	return err
+}
+
+// Size returns the number of rows and columns of the terminal, or 24 by 80 if it can't be
+// determined.
+func (t *terminal) Size() (int, int) {
+	var rows, cols int
+	out, err := stty(t.tty, "size")
+	if err != nil {
+		return 24, 80
+	}
+	if n, _ := fmt.Sscanf(out, "%d %d", &rows, &cols); n != 2 || rows == 0 || cols == 0 {
+		return 24, 80
+	}
+	return rows, cols
+}
+
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// readKeys decodes the key presses read from r and sends them on keys until r is exhausted.
+func readKeys(r io.Reader, keys chan<- rune) {
+	defer close(keys)
+
+	br := bufio.NewReader(r)
+	for {
+		c, _, err := br.ReadRune()
+		if err != nil {
+			return
+		}
+
+		switch c {
+		case '\r', '\n':
+			keys <- keyEnter
+		case 3:
+			keys <- keyInterrupt
+		case 127, 8:
+			keys <- keyBackspace
+		case 27:
+			// An arrow key arrives as ESC [ A, a lone ESC is the escape key itself.
+			if br.Buffered() < 2 {
+				keys <- keyEscape
+				continue
+			}
+			br.ReadByte()
+			switch b, _ := br.ReadByte(); b {
+			case 'A':
+				keys <- keyUp
+			case 'B':
+				keys <- keyDown
+			}
+		default:
+			keys <- c
+		}
+	}
+}
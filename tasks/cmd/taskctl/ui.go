@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/uber-apps/tasks/client"
+)
+
+// The UI is always in one of these modes.
+const (
+	browsing  = iota // moving the cursor through the list
+	choosing         // picking one of the selected task's actions
+	prompting        // entering a value an action needs
+)
+
+// ui is the state of the interactive task list. The actions it offers for a task are the
+// transitions the server advertises for it, so actions the server adds later appear without
+// changes to taskctl.
+type ui struct {
+	entry  *client.Resource
+	list   *client.Resource
+	items  []item
+	cursor int
+	mode   int
+
+	actions []*client.Transition
+	action  *client.Transition
+	params  []string
+	values  map[string]string
+	input   []rune
+
+	status string
+	quit   bool
+}
+
+// refresh reads the task list again, keeping the cursor in range.
+func (u *ui) refresh() {
+	list, err := u.entry.Follow("collection", nil)
+	if err != nil {
+		u.status = describe(err)
+		return
+	}
+
+	u.list, u.items = list, items(list)
+	if u.cursor >= len(u.items) {
+		u.cursor = len(u.items) - 1
+	}
+	if u.cursor < 0 {
+		u.cursor = 0
+	}
+}
+
+// handle updates the UI in response to a key press.
+func (u *ui) handle(key rune) {
+	if key == keyInterrupt {
+		u.quit = true
+		return
+	}
+
+	switch u.mode {
+	case browsing:
+		u.status = ""
+		switch key {
+		case keyUp, 'k':
+			if u.cursor > 0 {
+				u.cursor--
+			}
+		case keyDown, 'j':
+			if u.cursor < len(u.items)-1 {
+				u.cursor++
+			}
+		case keyEnter:
+			u.choose()
+		case 'a':
+			if t, err := u.entry.Transition("add"); err != nil {
+				u.status = err.Error()
+			} else {
+				u.begin(t)
+			}
+		case 'r':
+			u.refresh()
+		case 'q':
+			u.quit = true
+		}
+
+	case choosing:
+		switch {
+		case key == keyEscape || key == 'q':
+			u.mode = browsing
+		case key >= '1' && int(key-'1') < len(u.actions):
+			u.begin(u.actions[key-'1'])
+		}
+
+	case prompting:
+		switch key {
+		case keyEscape:
+			u.mode = browsing
+		case keyBackspace:
+			if len(u.input) > 0 {
+				u.input = u.input[:len(u.input)-1]
+			}
+		case keyEnter:
+			u.values[u.params[0]] = string(u.input)
+			u.params, u.input = u.params[1:], nil
+			if len(u.params) == 0 {
+				u.perform()
+			}
+		default:
+			if key >= ' ' {
+				u.input = append(u.input, key)
+			}
+		}
+	}
+}
+
+// choose offers the actions the server advertises for the task under the cursor.
+func (u *ui) choose() {
+	if len(u.items) == 0 {
+		return
+	}
+
+	item, err := u.list.Within(u.items[u.cursor].ID)
+	if err != nil {
+		u.status = err.Error()
+		return
+	}
+
+	u.actions = item.Transitions()
+	if len(u.actions) == 0 {
+		u.status = "no actions for " + u.items[u.cursor].ID
+		return
+	}
+	u.mode = choosing
+}
+
+// begin starts an action, prompting for the values it needs before performing it.
+func (u *ui) begin(t *client.Transition) {
+	u.action, u.params, u.values, u.input = t, t.Params(), map[string]string{}, nil
+	if len(u.params) > 0 {
+		u.mode = prompting
+		return
+	}
+	u.perform()
+}
+
+// perform carries out the chosen action and shows the list as it is afterwards.
+func (u *ui) perform() {
+	u.mode = browsing
+	if _, err := u.action.Do(u.values); err != nil {
+		u.status = describe(err)
+		return
+	}
+	u.status = u.action.Name() + " done"
+	u.refresh()
+}
+
+// render draws the UI on a screen of the given size.
+func (u *ui) render(w io.Writer, rows, cols int) {
+	lines := []string{bold + "Tasks" + reset, ""}
+
+	// Keep the cursor on screen, leaving room for the header and the footer.
+	visible := rows - 6
+	if visible < 1 {
+		visible = 1
+	}
+	first := 0
+	if u.cursor >= visible {
+		first = u.cursor - visible + 1
+	}
+
+	if len(u.items) == 0 {
+		lines = append(lines, "  no tasks")
+	}
+	for i := first; i < len(u.items) && i < first+visible; i++ {
+		line := truncate(fmt.Sprintf("  %-8s %s", u.items[i].ID, u.items[i].Text), cols)
+		if i == u.cursor {
+			line = reverse + line + reset
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "")
+
+	switch u.mode {
+	case browsing:
+		lines = append(lines, truncate("↑/↓ move  enter actions  a add  r refresh  q quit", cols))
+	case choosing:
+		choices := []string{}
+		for i, t := range u.actions {
+			choices = append(choices, fmt.Sprintf("%d %s", i+1, t.Name()))
+		}
+		lines = append(lines, truncate(strings.Join(choices, "  ")+"  esc cancel", cols))
+	case prompting:
+		lines = append(lines, truncate(fmt.Sprintf("%s %s: %s", u.action.Name(), u.params[0], string(u.input)), cols))
+	}
+	lines = append(lines, truncate(u.status, cols))
+
+	// The terminal is in raw mode, so every line needs its carriage return.
+	fmt.Fprint(w, clearScreen+strings.Join(lines, "\r\n"))
+}
+
+func truncate(s string, cols int) string {
+	if r := []rune(s); len(r) > cols {
+		return string(r[:cols])
+	}
+	return s
+}
+
+// runUI runs the interactive task list on the terminal until the user quits. The list is
+// refreshed whenever the server's change feed, the transition with rel events, reports a
+// change, or every interval if it doesn't advertise one.
+func runUI(entry *client.Resource, hc *http.Client, interval time.Duration) error {
+	term, err := openTerminal(os.Stdin)
+	if err != nil {
+		return err
+	}
+	defer term.Restore()
+	defer fmt.Print(clearScreen + showCursor)
+	fmt.Print(hideCursor)
+
+	keys := make(chan rune)
+	go readKeys(os.Stdin, keys)
+
+	changes := make(chan struct{}, 1)
+	go func() {
+		if t, err := entry.Transition("events"); err == nil {
+			if u, err := t.URL(nil); err == nil {
+				watch(hc, u.String(), changes)
+			}
+		}
+		for range time.Tick(interval) {
+			notify(changes)
+		}
+	}()
+
+	u := &ui{entry: entry}
+	u.refresh()
+	for !u.quit {
+		rows, cols := term.Size()
+		u.render(os.Stdout, rows, cols)
+
+		select {
+		case k, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			u.handle(k)
+		case <-changes:
+			u.refresh()
+		}
+	}
+	return nil
+}
+
+// watch reads the server-sent event stream at url, notifying changes of every event, until
+// the stream ends.
+func watch(hc *http.Client, url string, changes chan<- struct{}) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return
+	}
+
+	s := bufio.NewScanner(resp.Body)
+	data := false
+	for s.Scan() {
+		switch line := s.Text(); {
+		case strings.HasPrefix(line, "data:"):
+			data = true
+		case len(line) == 0 && data:
+			data = false
+			notify(changes)
+		}
+	}
+}
+
+// notify signals a change without blocking. Changes that arrive while one is pending are
+// folded into it.
+func notify(changes chan<- struct{}) {
+	select {
+	case changes <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uber-apps/tasks/client"
+)
+
+func TestReadKeys(t *testing.T) {
+	keys := make(chan rune)
+	go readKeys(strings.NewReader("j\x1b[Bk\x1b[A\rx\x7f\x03"), keys)
+
+	got := []rune{}
+	for k := range keys {
+		got = append(got, k)
+	}
+
+	expected := []rune{'j', keyDown, 'k', keyUp, keyEnter, 'x', keyBackspace, keyInterrupt}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Errorf("expected keys %v, got %v", expected, got)
+	}
+}
+
+func TestUI(t *testing.T) {
+	tt := []struct {
+		description string
+		keys        string
+		tasks       []string
+		status      string
+	}{
+		{"move and quit", "jjkq", []string{"task one", "task two"}, ""},
+		{"edit second task", "j\r1task deux\r", []string{"task one", "task deux"}, "edit done"},
+		{"cancel edit", "\r1task un\x1b", []string{"task one", "task two"}, ""},
+		{"correct typing", "\r1tsk\x7f\x7fask un\r", []string{"task un", "task two"}, "edit done"},
+		{"add task", "abuy milk\r", []string{"task one", "task two", "buy milk"}, "add done"},
+		{"no such action", "\r7\x1b", []string{"task one", "task two"}, ""},
+	}
+
+	for _, tst := range tt {
+		tasks, auth := []string{"task one", "task two"}, ""
+		ts := fakeServer(&tasks, &auth)
+
+		c, _ := client.New(ts.URL + "/")
+		entry, err := c.Entry()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u := &ui{entry: entry}
+		u.refresh()
+		for _, k := range tst.keys {
+			switch k {
+			case '\r':
+				k = keyEnter
+			case '\x1b':
+				k = keyEscape
+			case '\x7f':
+				k = keyBackspace
+			}
+			u.handle(k)
+		}
+		ts.Close()
+
+		if strings.Join(tasks, ",") != strings.Join(tst.tasks, ",") {
+			t.Errorf("%s: expected tasks %v, got %v", tst.description, tst.tasks, tasks)
+		}
+		if u.status != tst.status {
+			t.Errorf("%s: expected status %q, got %q", tst.description, tst.status, u.status)
+		}
+		if u.mode != browsing {
+			t.Errorf("%s: expected to be browsing, got mode %d", tst.description, u.mode)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	tasks, auth := []string{"task one", "task two", "a task whose text is far too long to fit"}, ""
+	ts := fakeServer(&tasks, &auth)
+	defer ts.Close()
+
+	c, _ := client.New(ts.URL + "/")
+	entry, err := c.Entry()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := &ui{entry: entry}
+	u.refresh()
+	u.handle('j')
+
+	buf := &bytes.Buffer{}
+	u.render(buf, 24, 30)
+	screen := buf.String()
+
+	if !strings.Contains(screen, reverse+"  task2    task two"+reset) {
+		t.Errorf("expected the second task to be highlighted, got %q", screen)
+	}
+	if strings.Contains(screen, "fit") {
+		t.Errorf("expected long lines to be truncated, got %q", screen)
+	}
+
+	u.handle(keyEnter)
+	buf.Reset()
+	u.render(buf, 24, 80)
+	if !strings.Contains(buf.String(), "1 edit") {
+		t.Errorf("expected the advertised actions to be offered, got %q", buf.String())
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": comment\n\nevent: changed\ndata: {}\n\n")
+	}))
+	defer ts.Close()
+
+	changes := make(chan struct{}, 1)
+	watch(http.DefaultClient, ts.URL, changes)
+
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Errorf("expected a change to be reported")
+	}
+}
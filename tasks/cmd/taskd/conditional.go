@@ -0,0 +1,117 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/uber-apps/tasks/uber"
+)
+
+// entity is the state of the task list, or of a single task, that a representation shows:
+// the store it is kept in, the version and, for a task, its position, which is its id. Every
+// task is last changed at a version of its own, so a task that takes another's position is a
+// different entity.
+type entity struct {
+	epoch    string
+	version  int
+	position int
+}
+
+// tag returns the strong entity tag of the representation of type mediaType of e, e.g.
+// "c7-3f2a9c01d2e4" for the task list at version 7 or "t5-8b1e0f4a6c2d" for a task last
+// changed at version 5. The digest covers the whole entity and the media type. It is
+// computed from the state rather than the body so that an If-Match tag can be checked by
+// computing the tags of the entity as it is now.
+func (e entity) tag(mediaType string) string {
+	kind := "c"
+	if e.position > 0 {
+		kind = "t"
+	}
+	sum := sha1.Sum([]byte(strings.Join([]string{kind, e.epoch, strconv.Itoa(e.version), strconv.Itoa(e.position), mediaType}, "\n")))
+	return fmt.Sprintf(`"%s%d-%x"`, kind, e.version, sum[:6])
+}
+
+// entityTags splits the value of an If-Match or If-None-Match header into its entity tags.
+func entityTags(header string) []string {
+	tags := []string{}
+	for _, t := range strings.Split(header, ",") {
+		if t = strings.TrimSpace(t); len(t) > 0 {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// notModified reports whether the request's If-None-Match header matches tag, in which case
+// a GET should be answered with 304 Not Modified. The comparison is weak, as RFC 7232
+// requires for If-None-Match.
+func notModified(req *http.Request, tag string) bool {
+	for _, t := range entityTags(req.Header.Get("If-None-Match")) {
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// precondition is the entity tags a client's If-Match header allows a change to be made to.
+type precondition struct {
+	present bool
+	any     bool
+	tags    []string
+}
+
+// ifMatch returns the precondition expressed by the request's If-Match header.
+func ifMatch(req *http.Request) precondition {
+	p := precondition{}
+	for _, t := range entityTags(req.Header.Get("If-Match")) {
+		p.present = true
+		if t == "*" {
+			p.any = true
+			continue
+		}
+		// Weak tags never match under the strong comparison If-Match requires.
+		if !strings.HasPrefix(t, "W/") {
+			p.tags = append(p.tags, t)
+		}
+	}
+	return p
+}
+
+// allows reports whether the precondition names the current tag of a representation of one
+// of the entities, the list or a task in it. The tags are compared whole, so a tag only
+// matches the kind of entity it was issued for, in the state it was issued in. Without an
+// If-Match header every entity is allowed.
+func (p precondition) allows(entities ...entity) bool {
+	if !p.present || p.any {
+		return true
+	}
+	for _, e := range entities {
+		for _, mt := range collectionTypes {
+			tag := e.tag(mt)
+			for _, t := range p.tags {
+				if t == tag {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// storeError returns the error to report for a failed change to the store.
+func storeError(err error) *uber.Error {
+	switch err {
+	case errNoSuchTask:
+		return newError(errTaskNotFound, "No such task")
+	case errConflictsFull:
+		return newError(errTooManyConflicts, "Too many unresolved conflicts")
+	case errNoSuchPosition:
+		return newError(errInvalidBody, "Invalid move task body",
+			uber.FieldError{Field: "position", Code: fieldInvalid, Message: "The list has no such position"})
+	}
+	return newError(errPreconditionFailed, "The task list has changed since it was read")
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/uber-apps/tasks/uber"
+)
+
+func TestStoreVersions(t *testing.T) {
+	s := newStore("task one", "task two")
+	if c := s.snapshot(nil); c.version != 2 || len(c.tasks) != 2 {
+		t.Fatalf("expected 2 tasks at version 2, got %d at version %d", len(c.tasks), c.version)
+	}
+
+	if err := s.edit(1, "task un", precondition{}); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := s.get(1); c.version != 3 {
+		t.Errorf("expected an edited task to take the new version 3, got %d", c.version)
+	}
+	if c, _ := s.get(2); c.version != 2 {
+		t.Errorf("expected an untouched task to keep version 2, got %d", c.version)
+	}
+
+	if err := s.complete(1, precondition{}); err != nil {
+		t.Fatal(err)
+	}
+	if c, _ := s.get(1); c.tasks[0].id != "task1" || c.tasks[0].text != "task two" || c.version != 2 {
+		t.Errorf("expected task two to move up keeping its version, got %+v", c)
+	}
+	if err := s.complete(2, precondition{}); err != errNoSuchTask {
+		t.Errorf("expected errNoSuchTask, got %v", err)
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	usecontext(t, multipletasks())
+	r := router()
+
+	do := func(method, target, payload string, headers ...string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, target, strings.NewReader(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	list := do(GET, "/tasks", "")
+	listTag := list.Header().Get("ETag")
+	if !strings.HasPrefix(listTag, `"c3-`) {
+		t.Fatalf("expected a strong ETag for the list at version 3, got %q", listTag)
+	}
+	if tag := do(GET, "/tasks", "", "Accept", halType).Header().Get("ETag"); tag == listTag {
+		t.Errorf("expected representations to have different ETags, both got %q", tag)
+	}
+
+	item := do(GET, "/tasks/task2", "")
+	itemTag := item.Header().Get("ETag")
+	if item.Code != 200 || !strings.HasPrefix(itemTag, `"t2-`) {
+		t.Errorf("expected task2 at version 2, got %d %q", item.Code, itemTag)
+	}
+
+	tt := []struct {
+		description string
+		method      string
+		target      string
+		payload     string
+		headers     []string
+		rc          int
+	}{
+		{"unchanged list", GET, "/tasks", "", []string{"If-None-Match", listTag}, 304},
+		{"unchanged list, weak", GET, "/tasks", "", []string{"If-None-Match", "W/" + listTag}, 304},
+		{"unchanged task", GET, "/tasks/task2", "", []string{"If-None-Match", itemTag}, 304},
+		{"missing task", GET, "/tasks/task9", "", nil, 404},
+		{"edit another task", PUT, "/tasks/edit", "id=task3&text=task trois", []string{"If-Match", listTag}, 204},
+		{"list has changed", GET, "/tasks", "", []string{"If-None-Match", listTag}, 200},
+		{"stale list version", POST, "/tasks", "text=task four", []string{"If-Match", listTag}, 412},
+		{"weak tag", PUT, "/tasks/edit", "id=task2&text=task deux", []string{"If-Match", "W/" + itemTag}, 412},
+		{"task version still current", PUT, "/tasks/edit", "id=task2&text=task deux", []string{"If-Match", itemTag}, 204},
+		{"task has changed", POST, "/tasks/complete", "id=task2", []string{"If-Match", itemTag}, 412},
+		{"any version", POST, "/tasks/complete", "id=task2", []string{"If-Match", "*"}, 204},
+		{"no precondition", POST, "/tasks", "text=task four", nil, 204},
+	}
+
+	for _, tst := range tt {
+		w := do(tst.method, tst.target, tst.payload, tst.headers...)
+		if w.Code != tst.rc {
+			t.Errorf("%s: expected status %d, got %d", tst.description, tst.rc, w.Code)
+			continue
+		}
+
+		if w.Code == http.StatusPreconditionFailed {
+			ud, err := uber.Parse(w.Body)
+			if err != nil {
+				t.Errorf("%s: %v", tst.description, err)
+				continue
+			}
+			if e := uber.ErrorFromDoc(ud); e == nil || e.Code != errPreconditionFailed {
+				t.Errorf("%s: expected error %s, got %v", tst.description, errPreconditionFailed, e)
+			}
+		}
+	}
+}
+
+func TestConditionalInterleaving(t *testing.T) {
+	tt := []struct {
+		description string
+		tag         func(list, task2, hal string) string
+		rc          int
+		texts       string
+	}{
+		{"stale list tag", func(list, task2, hal string) string { return list }, 412, "task two,task three"},
+		{"task tag for the position", func(list, task2, hal string) string { return task2 }, 412, "task two,task three"},
+		{"tag with another digest", func(list, task2, hal string) string { return list[:len(list)-5] + `0000"` }, 412, "task two,task three"},
+		{"current tag of another representation", func(list, task2, hal string) string { return hal }, 204, "task two"},
+	}
+
+	for _, tst := range tt {
+		usecontext(t, multipletasks())
+		r := router()
+		store := taskctx.Value("tasks").(*taskstore)
+
+		do := func(method, target, payload string, headers ...string) *httptest.ResponseRecorder {
+			req, err := http.NewRequest(method, target, strings.NewReader(payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i+1 < len(headers); i += 2 {
+				req.Header.Set(headers[i], headers[i+1])
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w
+		}
+
+		list := do(GET, "/tasks", "").Header().Get("ETag")
+		task2 := do(GET, "/tasks/task2", "").Header().Get("ETag")
+
+		// Another client completes task one, so task2 now names task three.
+		if w := do(POST, "/tasks/complete", "id=task1"); w.Code != 204 {
+			t.Fatalf("%s: completing task1: expected status 204, got %d", tst.description, w.Code)
+		}
+		hal := do(GET, "/tasks", "", "Accept", halType).Header().Get("ETag")
+
+		if w := do(POST, "/tasks/complete", "id=task2", "If-Match", tst.tag(list, task2, hal)); w.Code != tst.rc {
+			t.Errorf("%s: expected status %d, got %d", tst.description, tst.rc, w.Code)
+		}
+		texts := []string{}
+		for _, t := range store.snapshot(nil).tasks {
+			texts = append(texts, t.text)
+		}
+		if strings.Join(texts, ",") != tst.texts {
+			t.Errorf("%s: expected tasks %s, got %v", tst.description, tst.texts, texts)
+		}
+	}
+}
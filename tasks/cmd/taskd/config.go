@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/github.com/gorilla/handlers"
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/github.com/gorilla/mux"
+)
+
+// envPrefix prefixes the environment variables taskd reads its settings from. The variable
+// for a flag is its name in upper case with dashes turned into underscores, e.g.
+// TASKD_CORS_ORIGINS for -cors-origins.
+const envPrefix = "TASKD_"
+
+// config is taskd's configuration. Every setting is a command line flag, and can also be given
+// by an environment variable or in a config file. Flags take precedence over the environment,
+// the environment over the file and the file over the defaults.
+type config struct {
+	file        string
+	listen      string
+	store       string
+	storePath   string
+	logFormat   string
+	logLevel    string
+	tlsCert     string
+	tlsKey      string
+	maxBody     int64
+	maxHeader   int
+	readTimeout time.Duration
+	assets      string
+	idempotency time.Duration
+	cors        corsPolicy
+}
+
+// defaultConfig returns the configuration taskd runs with when nothing is set.
+func defaultConfig() config {
+	return config{
+		listen:      ":3006",
+		store:       "memory",
+		logFormat:   "common",
+		logLevel:    "info",
+		maxBody:     1 << 20,
+		maxHeader:   http.DefaultMaxHeaderBytes,
+		idempotency: idempotencyWindow,
+		cors:        cors,
+	}
+}
+
+// listValue is a flag holding a comma separated list.
+type listValue struct {
+	list *[]string
+}
+
+func (l listValue) String() string {
+	if l.list == nil {
+		return ""
+	}
+	return strings.Join(*l.list, ",")
+}
+
+func (l listValue) Set(s string) error {
+	*l.list = splitList(s)
+	return nil
+}
+
+// flags returns the command line flags that set c.
+func (c *config) flags() *flag.FlagSet {
+	fs := flag.NewFlagSet("taskd", flag.ContinueOnError)
+	fs.StringVar(&c.file, "config", c.file, "read settings from the JSON config `file`")
+	fs.StringVar(&c.listen, "listen", c.listen, "listen on `address`")
+	fs.StringVar(&c.store, "store", c.store, "keep tasks in the `backend`, memory or file")
+	fs.StringVar(&c.storePath, "store-path", c.storePath, "save tasks to `file` when the store is file")
+	fs.StringVar(&c.logFormat, "log-format", c.logFormat, "log requests in the common or combined log `format`")
+	fs.StringVar(&c.logLevel, "log-level", c.logLevel, "log requests and errors at `level` info, only errors at level error")
+	fs.StringVar(&c.tlsCert, "tls-cert", c.tlsCert, "serve HTTPS with the certificate in `file`")
+	fs.StringVar(&c.tlsKey, "tls-key", c.tlsKey, "serve HTTPS with the private key in `file`")
+	fs.Int64Var(&c.maxBody, "max-body", c.maxBody, "refuse request bodies larger than `bytes`")
+	fs.IntVar(&c.maxHeader, "max-header", c.maxHeader, "refuse request headers larger than `bytes`")
+	fs.DurationVar(&c.readTimeout, "read-timeout", c.readTimeout, "give clients `duration` to send a request, 0 for no limit")
+	fs.StringVar(&c.assets, "assets", c.assets, "serve the browser client from `dir` instead of the embedded copy")
+	fs.DurationVar(&c.idempotency, "idempotency-window", c.idempotency, "remember Idempotency-Keys for `duration`")
+	fs.Var(listValue{&c.cors.origins}, "cors-origins", "allow cross-origin requests from the comma separated `origins`, * for any")
+	fs.Var(listValue{&c.cors.methods}, "cors-methods", "allow only the comma separated `methods` cross-origin, instead of every registered one")
+	fs.Var(listValue{&c.cors.headers}, "cors-headers", "allow the comma separated request `headers` cross-origin")
+	fs.BoolVar(&c.cors.credentials, "cors-credentials", c.cors.credentials, "allow cross-origin requests with credentials")
+	fs.DurationVar(&c.cors.maxAge, "cors-max-age", c.cors.maxAge, "let browsers cache preflight responses for `duration`")
+	return fs
+}
+
+// envName returns the name of the environment variable for the flag with name.
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// loadConfig returns the configuration set by the command line args, the environment, as
+// seen through lookupEnv, and the config file either of them names. Usage messages for bad
+// flags are written to stderr.
+func loadConfig(args []string, lookupEnv func(string) (string, bool), stderr io.Writer) (config, error) {
+	c := defaultConfig()
+	fs := c.flags()
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+	if fs.NArg() > 0 {
+		return c, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	if v, ok := lookupEnv(envName("config")); ok && !set["config"] {
+		c.file = v
+	}
+	file := map[string]string{}
+	if len(c.file) > 0 {
+		var err error
+		if file, err = readConfigFile(c.file); err != nil {
+			return c, err
+		}
+		for name := range file {
+			if f := fs.Lookup(name); f == nil || name == "config" {
+				return c, fmt.Errorf("%s: unknown setting %q", c.file, name)
+			}
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || set[f.Name] || f.Name == "config" {
+			return
+		}
+		if v, ok := lookupEnv(envName(f.Name)); ok {
+			if e := f.Value.Set(v); e != nil {
+				err = fmt.Errorf("invalid value %q for %s: %v", v, envName(f.Name), e)
+			}
+			return
+		}
+		if v, ok := file[f.Name]; ok {
+			if e := f.Value.Set(v); e != nil {
+				err = fmt.Errorf("%s: invalid value %q for %s: %v", c.file, v, f.Name, e)
+			}
+		}
+	})
+	if err != nil {
+		return c, err
+	}
+
+	return c, c.validate()
+}
+
+// readConfigFile reads a config file, a JSON object whose properties are named after the
+// flags they set, e.g.
+//
+//	{"listen": ":8443", "tls-cert": "cert.pem", "tls-key": "key.pem", "cors-origins": ["https://tasks.example.com"]}
+//
+// Values are strings, numbers, booleans or, for lists, arrays of strings.
+func readConfigFile(path string) (map[string]string, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]interface{}
+	if err := json.Unmarshal(bs, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	settings := map[string]string{}
+	for name, v := range raw {
+		switch v := v.(type) {
+		case string:
+			settings[name] = v
+		case float64:
+			settings[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			settings[name] = strconv.FormatBool(v)
+		case []interface{}:
+			list := []string{}
+			for _, e := range v {
+				s, ok := e.(string)
+				if !ok {
+					return nil, fmt.Errorf("%s: %s must be a list of strings", path, name)
+				}
+				list = append(list, s)
+			}
+			settings[name] = strings.Join(list, ",")
+		default:
+			return nil, fmt.Errorf("%s: %s must be a string, number, boolean or list of strings", path, name)
+		}
+	}
+	return settings, nil
+}
+
+// validate checks that the settings make sense together.
+func (c config) validate() error {
+	switch {
+	case c.store != "memory" && c.store != "file":
+		return fmt.Errorf("unknown store %q, expected memory or file", c.store)
+	case c.store == "file" && len(c.storePath) == 0:
+		return errors.New("the file store needs a store-path")
+	case c.logFormat != "common" && c.logFormat != "combined":
+		return fmt.Errorf("unknown log format %q, expected common or combined", c.logFormat)
+	case c.logLevel != "info" && c.logLevel != "error":
+		return fmt.Errorf("unknown log level %q, expected info or error", c.logLevel)
+	case (len(c.tlsCert) == 0) != (len(c.tlsKey) == 0):
+		return errors.New("serving HTTPS needs both tls-cert and tls-key")
+	case c.maxBody <= 0:
+		return errors.New("max-body must be positive")
+	case c.maxHeader <= 0:
+		return errors.New("max-header must be positive")
+	case c.readTimeout < 0:
+		return errors.New("read-timeout must not be negative")
+	}
+	return c.cors.validate()
+}
+
+// print writes the settings of c as a config file.
+func (c config) print(w io.Writer) error {
+	settings := map[string]string{}
+	c.flags().VisitAll(func(f *flag.Flag) {
+		if f.Name != "config" {
+			settings[f.Name] = f.Value.String()
+		}
+	})
+
+	bs, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", bs)
+	return err
+}
+
+// apply installs the settings that live in package variables.
+func (c config) apply() {
+	assetdir, idempotencyWindow, cors = c.assets, c.idempotency, c.cors
+}
+
+// openStore returns the store c names.
+func (c config) openStore() (*taskstore, error) {
+	if c.store == "file" {
+		return loadStore(c.storePath)
+	}
+	return newStore(), nil
+}
+
+// handler wraps r in the middleware c asks for.
+func (c config) handler(r *mux.Router) http.Handler {
+	h := profileLink(trimSlash(limitBody(c.maxBody, allowCORS(r))))
+	switch {
+	case c.logLevel == "error":
+		// Errors go to the logger in the handlers' context, requests aren't logged.
+	case c.logFormat == "combined":
+		h = handlers.CombinedLoggingHandler(os.Stdout, h)
+	default:
+		h = handlers.LoggingHandler(os.Stdout, h)
+	}
+	return compress(h)
+}
+
+// server returns the HTTP server c describes, serving h.
+func (c config) server(h http.Handler, logger *log.Logger) *http.Server {
+	return &http.Server{Addr: c.listen, Handler: h, ReadTimeout: c.readTimeout, MaxHeaderBytes: c.maxHeader, ErrorLog: logger}
+}
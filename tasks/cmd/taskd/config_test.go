@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "taskd-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	full := file("full.json", `{"listen": ":8080", "max-body": 2048, "cors-credentials": true, "cors-origins": ["https://a.example.com", "https://b.example.com"], "read-timeout": "5s"}`)
+	unknown := file("unknown.json", `{"port": 8080}`)
+	nested := file("nested.json", `{"listen": {"port": 8080}}`)
+	malformed := file("malformed.json", `{"listen": `)
+
+	tt := []struct {
+		description string
+		args        []string
+		env         map[string]string
+		check       func(c config) bool
+		err         string
+	}{
+		{"defaults", nil, nil,
+			func(c config) bool { return c.listen == ":3006" && c.store == "memory" && c.maxBody == 1<<20 }, ""},
+		{"flags", []string{"-listen", ":9000", "-cors-origins", "https://a.example.com, https://b.example.com"}, nil,
+			func(c config) bool { return c.listen == ":9000" && len(c.cors.origins) == 2 }, ""},
+		{"environment", nil, map[string]string{"TASKD_LISTEN": ":9001", "TASKD_IDEMPOTENCY_WINDOW": "1h"},
+			func(c config) bool { return c.listen == ":9001" && c.idempotency == time.Hour }, ""},
+		{"file", []string{"-config", full}, nil,
+			func(c config) bool {
+				return c.listen == ":8080" && c.maxBody == 2048 && c.cors.credentials && c.readTimeout == 5*time.Second &&
+					strings.Join(c.cors.origins, " ") == "https://a.example.com https://b.example.com"
+			}, ""},
+		{"file named by the environment", nil, map[string]string{"TASKD_CONFIG": full},
+			func(c config) bool { return c.listen == ":8080" }, ""},
+		{"environment over file", []string{"-config", full}, map[string]string{"TASKD_LISTEN": ":9001"},
+			func(c config) bool { return c.listen == ":9001" && c.maxBody == 2048 }, ""},
+		{"flags over environment", []string{"-listen", ":9000"}, map[string]string{"TASKD_LISTEN": ":9001"},
+			func(c config) bool { return c.listen == ":9000" }, ""},
+		{"flag over file", []string{"-config", full, "-max-body", "4096"}, nil,
+			func(c config) bool { return c.listen == ":8080" && c.maxBody == 4096 }, ""},
+		{"file store", []string{"-store", "file", "-store-path", "tasks.json"}, nil,
+			func(c config) bool { return c.store == "file" && c.storePath == "tasks.json" }, ""},
+		{"unknown flag", []string{"-port", "8080"}, nil, nil, "flag provided but not defined"},
+		{"extra argument", []string{"serve"}, nil, nil, "unexpected argument"},
+		{"invalid environment value", nil, map[string]string{"TASKD_MAX_BODY": "lots"}, nil, "TASKD_MAX_BODY"},
+		{"unknown setting in file", []string{"-config", unknown}, nil, nil, `unknown setting "port"`},
+		{"nested value in file", []string{"-config", nested}, nil, nil, "listen must be"},
+		{"malformed file", []string{"-config", malformed}, nil, nil, "malformed.json"},
+		{"missing file", []string{"-config", filepath.Join(dir, "missing.json")}, nil, nil, "missing.json"},
+		{"unknown store", []string{"-store", "redis"}, nil, nil, "unknown store"},
+		{"file store without path", []string{"-store", "file"}, nil, nil, "store-path"},
+		{"unknown log format", []string{"-log-format", "json"}, nil, nil, "unknown log format"},
+		{"unknown log level", []string{"-log-level", "debug"}, nil, nil, "unknown log level"},
+		{"credentials for any origin", []string{"-cors-credentials"}, nil, nil, "cors-origins"},
+		{"credentials for any origin in the environment", []string{"-cors-origins", "https://a.example.com,*"}, map[string]string{"TASKD_CORS_CREDENTIALS": "true"}, nil, "cors-origins"},
+		{"certificate without key", []string{"-tls-cert", "cert.pem"}, nil, nil, "tls-key"},
+		{"no body allowed", []string{"-max-body", "0"}, nil, nil, "max-body"},
+	}
+
+	for _, tst := range tt {
+		lookupEnv := func(name string) (string, bool) {
+			v, ok := tst.env[name]
+			return v, ok
+		}
+
+		c, err := loadConfig(tst.args, lookupEnv, ioutil.Discard)
+		if len(tst.err) > 0 {
+			if err == nil || !strings.Contains(err.Error(), tst.err) {
+				t.Errorf("%s: expected an error containing %q, got %v", tst.description, tst.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tst.description, err)
+			continue
+		}
+		if !tst.check(c) {
+			t.Errorf("%s: unexpected configuration %+v", tst.description, c)
+		}
+	}
+}
+
+func TestConfigPrint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "taskd-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	noEnv := func(string) (string, bool) { return "", false }
+	c, err := loadConfig([]string{"-listen", ":9000", "-cors-methods", "GET,POST", "-log-level", "error"}, noEnv, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err := c.print(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, setting := range []string{`"listen": ":9000"`, `"cors-methods": "GET,POST"`, `"store": "memory"`, `"max-body": "1048576"`} {
+		if !strings.Contains(b.String(), setting) {
+			t.Errorf("expected the printed configuration to contain %s, got\n%s", setting, b.String())
+		}
+	}
+	if strings.Contains(b.String(), `"config"`) {
+		t.Errorf("expected the printed configuration not to name a config file")
+	}
+
+	path := filepath.Join(dir, "printed.json")
+	if err := ioutil.WriteFile(path, b.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := loadConfig([]string{"-config", path}, noEnv, ioutil.Discard)
+	if err != nil {
+		t.Fatalf("loading the printed configuration: %v", err)
+	}
+	reloaded.file = ""
+	var again bytes.Buffer
+	reloaded.print(&again)
+	if again.String() != b.String() {
+		t.Errorf("expected the printed configuration to load as itself, got\n%s", again.String())
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/github.com/gorilla/mux"
+)
+
+// corsPolicy says which cross-origin requests browsers may make to taskd. An origin of "*"
+// allows every origin, and without methods every method a route is registered for is
+// allowed. Credentials are only allowed for the origins listed by name.
+type corsPolicy struct {
+	origins     []string
+	methods     []string
+	headers     []string
+	exposed     []string
+	credentials bool
+	maxAge      time.Duration
+}
+
+// cors is the policy taskd enforces. Like the node server it allows every origin by
+// default.
+var cors = corsPolicy{
+	origins: []string{"*"},
+	headers: []string{"Accept", "Content-Type", "If-Match", "If-None-Match", "Idempotency-Key"},
+	exposed: []string{"ETag", "Link", "Location", "Idempotent-Replayed"},
+	maxAge:  10 * time.Minute,
+}
+
+// probeMethods are the methods tried when working out which ones a path is registered for.
+var probeMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// methodsFor returns the methods r has a route for at the request's path.
+func methodsFor(r *mux.Router, req *http.Request) []string {
+	methods := []string{}
+	for _, m := range probeMethods {
+		probe := *req
+		probe.Method = m
+
+		var match mux.RouteMatch
+		if r.Match(&probe, &match) {
+			methods = append(methods, m)
+		}
+	}
+	return methods
+}
+
+// allowCORS applies the cors policy to the requests r handles. Preflight requests for any
+// path r has routes for are answered directly, as are plain OPTIONS requests, with the
+// methods registered for the path.
+func allowCORS(r *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		origin := req.Header.Get("Origin")
+		allowed := len(origin) > 0 && cors.allowsOrigin(origin)
+
+		if allowed {
+			if contains(cors.origins, origin) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+				if cors.credentials {
+					w.Header().Set("Access-Control-Allow-Credentials", "true")
+				}
+			} else {
+				// Origins only allowed by "*" never get credentials: reflecting them would let
+				// any site make requests with the user's cookies.
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			}
+		}
+
+		if req.Method != "OPTIONS" {
+			if allowed && len(cors.exposed) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(cors.exposed, ", "))
+			}
+			r.ServeHTTP(w, req)
+			return
+		}
+
+		methods := methodsFor(r, req)
+		if len(methods) == 0 {
+			r.ServeHTTP(w, req)
+			return
+		}
+
+		w.Header().Set("Allow", strings.Join(append(methods, "OPTIONS"), ", "))
+		if allowed && len(req.Header.Get("Access-Control-Request-Method")) > 0 {
+			w.Header().Set("Access-Control-Allow-Methods", strings.Join(cors.allowedMethods(methods), ", "))
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(cors.headers, ", "))
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cors.maxAge.Seconds())))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func (p corsPolicy) allowsOrigin(origin string) bool {
+	return contains(p.origins, "*") || contains(p.origins, origin)
+}
+
+// validate returns an error if the policy allows credentials for any origin, which it
+// can't safely do.
+func (p corsPolicy) validate() error {
+	if p.credentials && contains(p.origins, "*") {
+		return errors.New("cors-credentials needs the cors-origins listed by name, not *")
+	}
+	return nil
+}
+
+// allowedMethods returns those of the methods registered for a path the policy allows.
+func (p corsPolicy) allowedMethods(registered []string) []string {
+	if len(p.methods) == 0 {
+		return registered
+	}
+
+	methods := []string{}
+	for _, m := range registered {
+		if contains(p.methods, m) {
+			methods = append(methods, m)
+		}
+	}
+	return methods
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList splits a comma separated flag value, dropping empty elements.
+func splitList(s string) []string {
+	list := []string{}
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); len(e) > 0 {
+			list = append(list, e)
+		}
+	}
+	return list
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORS(t *testing.T) {
+	defaults := cors
+	defer func() { cors = defaults }()
+
+	restricted := corsPolicy{
+		origins:     []string{"https://tasks.example.com"},
+		methods:     []string{"GET", "POST"},
+		headers:     []string{"Content-Type"},
+		credentials: true,
+		maxAge:      time.Minute,
+	}
+
+	careless := corsPolicy{
+		origins:     []string{"*", "https://tasks.example.com"},
+		credentials: true,
+	}
+
+	tt := []struct {
+		description string
+		policy      corsPolicy
+		method      string
+		url         string
+		headers     map[string]string
+		rc          int
+		expected    map[string]string
+	}{
+		{"same origin", defaults, GET, "/tasks", nil, 200,
+			map[string]string{"Access-Control-Allow-Origin": ""}},
+		{"any origin", defaults, GET, "/tasks", map[string]string{"Origin": "http://localhost:8080"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Expose-Headers": "ETag, Link, Location, Idempotent-Replayed"}},
+		{"preflight add", defaults, "OPTIONS", "/tasks", map[string]string{"Origin": "http://localhost:8080", "Access-Control-Request-Method": "POST"}, 204,
+			map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Methods": "GET, POST", "Access-Control-Max-Age": "600", "Allow": "GET, POST, OPTIONS"}},
+		{"preflight edit", defaults, "OPTIONS", "/tasks/edit", map[string]string{"Origin": "http://localhost:8080", "Access-Control-Request-Method": "PUT"}, 204,
+			map[string]string{"Access-Control-Allow-Methods": "POST, PUT", "Access-Control-Allow-Headers": "Accept, Content-Type, If-Match, If-None-Match, Idempotency-Key"}},
+		{"preflight task", defaults, "OPTIONS", "/tasks/task1", map[string]string{"Origin": "http://localhost:8080", "Access-Control-Request-Method": "GET"}, 204,
+			map[string]string{"Access-Control-Allow-Methods": "GET"}},
+		{"preflight unknown path", defaults, "OPTIONS", "/nowhere", map[string]string{"Origin": "http://localhost:8080", "Access-Control-Request-Method": "GET"}, 404,
+			map[string]string{"Access-Control-Allow-Methods": ""}},
+		{"plain options", defaults, "OPTIONS", "/tasks/complete", nil, 204,
+			map[string]string{"Allow": "POST, OPTIONS", "Access-Control-Allow-Methods": ""}},
+		{"allowed origin with credentials", restricted, GET, "/tasks", map[string]string{"Origin": "https://tasks.example.com"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": "https://tasks.example.com", "Access-Control-Allow-Credentials": "true", "Vary": "Origin"}},
+		{"disallowed origin", restricted, GET, "/tasks", map[string]string{"Origin": "https://evil.example.com"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": ""}},
+		{"credentials for a listed origin", careless, GET, "/tasks", map[string]string{"Origin": "https://tasks.example.com"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": "https://tasks.example.com", "Access-Control-Allow-Credentials": "true"}},
+		{"no credentials for any origin", careless, GET, "/tasks", map[string]string{"Origin": "https://evil.example.com"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Credentials": ""}},
+		{"no credentials for any origin on preflight", careless, "OPTIONS", "/tasks", map[string]string{"Origin": "https://evil.example.com", "Access-Control-Request-Method": "POST"}, 204,
+			map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Credentials": ""}},
+		{"restricted methods", restricted, "OPTIONS", "/tasks/edit", map[string]string{"Origin": "https://tasks.example.com", "Access-Control-Request-Method": "PUT"}, 204,
+			map[string]string{"Access-Control-Allow-Methods": "POST", "Access-Control-Max-Age": "60", "Access-Control-Allow-Headers": "Content-Type"}},
+	}
+
+	for _, tst := range tt {
+		cors = tst.policy
+		usecontext(t, onetask())
+
+		req, err := http.NewRequest(tst.method, tst.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range tst.headers {
+			req.Header.Set(k, v)
+		}
+
+		w := httptest.NewRecorder()
+		allowCORS(router()).ServeHTTP(w, req)
+
+		if w.Code != tst.rc {
+			t.Errorf("%s: expected status %d, got %d", tst.description, tst.rc, w.Code)
+		}
+		for k, v := range tst.expected {
+			if got := w.Header().Get(k); got != v {
+				t.Errorf("%s: expected %s %q, got %q", tst.description, k, v, got)
+			}
+		}
+	}
+}
+
+func TestCORSValidate(t *testing.T) {
+	tt := []struct {
+		description string
+		policy      corsPolicy
+		valid       bool
+	}{
+		{"any origin", corsPolicy{origins: []string{"*"}}, true},
+		{"credentials for listed origins", corsPolicy{origins: []string{"https://tasks.example.com"}, credentials: true}, true},
+		{"credentials for any origin", corsPolicy{origins: []string{"*"}, credentials: true}, false},
+		{"credentials for any origin among listed ones", corsPolicy{origins: []string{"https://tasks.example.com", "*"}, credentials: true}, false},
+	}
+
+	for _, tst := range tt {
+		if err := tst.policy.validate(); (err == nil) != tst.valid {
+			t.Errorf("%s: expected valid %v, got %v", tst.description, tst.valid, err)
+		}
+	}
+}
@@ -22,25 +22,54 @@ var (
 							"id": "list", 
 							"name": "links",
 							"rel": [ "collection" ], 
-							"url": "/tasks/", 
-							"action": "read" 
+							"url": "/tasks", 
+							"action": "read",
+							"accepting": [ "application/vnd.uber+json", "application/json", "text/html", "application/hal+json", "application/vnd.siren+json", "application/vnd.collection+json" ]
 						},
 						{ 
 							"id": "search", 
 							"name": "links",
 							"rel": [ "search" ], 
-							"url": "/tasks/search", 
+							"url": "/tasks/search{?text}", 
+							"template": true,
 							"action": "read",
-							"model": "?text={text}"
+							"accepting": [ "application/vnd.uber+json", "application/json", "text/html", "application/hal+json", "application/vnd.siren+json", "application/vnd.collection+json" ]
 						},
 						{ 
 							"id": "add", 
 							"name": "links",
 							"rel": [ "add" ], 
-							"url": "/tasks/", 
+							"url": "/tasks", 
 							"action": "append",
-							"model": "text={text}"
-						} 
+							"model": "text={text}",
+							"sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json",
+							"accepting": [ "application/vnd.uber+json", "text/html" ]
+						},
+						{
+							"id": "metadata",
+							"name": "links",
+							"rel": [ "metadata" ],
+							"url": "/tasks/metadata",
+							"action": "read",
+							"accepting": [ "application/vnd.uber+json", "text/html" ]
+						},
+						{
+							"id": "events",
+							"name": "links",
+							"rel": [ "events" ],
+							"url": "/tasks/events",
+							"action": "read",
+							"accepting": [ "text/event-stream" ]
+						},
+						{
+							"id": "sync",
+							"name": "links",
+							"rel": [ "sync" ],
+							"url": "/tasks/sync{?cursor}",
+							"template": true,
+							"action": "read",
+							"accepting": [ "application/vnd.uber+json", "text/html" ]
+						}
 					] 
 				},
 				{
@@ -70,25 +99,54 @@ var (
 							"id": "list", 
 							"name": "links",
 							"rel": [ "collection" ], 
-							"url": "/tasks/", 
-							"action": "read" 
+							"url": "/tasks", 
+							"action": "read",
+							"accepting": [ "application/vnd.uber+json", "application/json", "text/html", "application/hal+json", "application/vnd.siren+json", "application/vnd.collection+json" ]
 						},
 						{ 
 							"id": "search", 
 							"name": "links",
 							"rel": [ "search" ], 
-							"url": "/tasks/search", 
+							"url": "/tasks/search{?text}", 
+							"template": true,
 							"action": "read",
-							"model": "?text={text}"
+							"accepting": [ "application/vnd.uber+json", "application/json", "text/html", "application/hal+json", "application/vnd.siren+json", "application/vnd.collection+json" ]
 						},
 						{ 
 							"id": "add", 
 							"name": "links",
 							"rel": [ "add" ], 
-							"url": "/tasks/", 
+							"url": "/tasks", 
 							"action": "append",
-							"model": "text={text}"
-						} 
+							"model": "text={text}",
+							"sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json",
+							"accepting": [ "application/vnd.uber+json", "text/html" ]
+						},
+						{
+							"id": "metadata",
+							"name": "links",
+							"rel": [ "metadata" ],
+							"url": "/tasks/metadata",
+							"action": "read",
+							"accepting": [ "application/vnd.uber+json", "text/html" ]
+						},
+						{
+							"id": "events",
+							"name": "links",
+							"rel": [ "events" ],
+							"url": "/tasks/events",
+							"action": "read",
+							"accepting": [ "text/event-stream" ]
+						},
+						{
+							"id": "sync",
+							"name": "links",
+							"rel": [ "sync" ],
+							"url": "/tasks/sync{?cursor}",
+							"template": true,
+							"action": "read",
+							"accepting": [ "application/vnd.uber+json", "text/html" ]
+						}
 					] 
 				},
 				{
@@ -101,7 +159,10 @@ var (
 							"rel": [ "item" ],
 							"data": 
 							[
-								{ "rel": [ "complete" ], "url": "/tasks/complete/", "action": "append", "model": "id=task1"},
+								{ "rel": [ "self" ], "url": "/tasks/task1", "action": "read", "accepting": [ "application/vnd.uber+json", "application/json", "text/html", "application/hal+json", "application/vnd.siren+json", "application/vnd.collection+json" ]},
+								{ "rel": [ "complete" ], "url": "/tasks/complete", "action": "append", "model": "id=task1", "sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json", "accepting": [ "application/vnd.uber+json", "text/html" ]},
+								{ "rel": [ "edit" ], "url": "/tasks/edit", "action": "replace", "model": "id=task1\u0026text={text}", "sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json", "accepting": [ "application/vnd.uber+json", "text/html" ]},
+								{ "rel": [ "move" ], "url": "/tasks/move", "action": "replace", "model": "id=task1\u0026position={position}", "sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json", "accepting": [ "application/vnd.uber+json", "text/html" ]},
 								{ "name": "text", "value": "task one" }
 							]
 						}
@@ -131,25 +192,54 @@ var (
 							"id": "list", 
 							"name": "links",
 							"rel": [ "collection" ], 
-							"url": "/tasks/", 
-							"action": "read" 
+							"url": "/tasks", 
+							"action": "read",
+							"accepting": [ "application/vnd.uber+json", "application/json", "text/html", "application/hal+json", "application/vnd.siren+json", "application/vnd.collection+json" ]
 						},
 						{ 
 							"id": "search", 
 							"name": "links",
 							"rel": [ "search" ], 
-							"url": "/tasks/search", 
+							"url": "/tasks/search{?text}", 
+							"template": true,
 							"action": "read",
-							"model": "?text={text}"
+							"accepting": [ "application/vnd.uber+json", "application/json", "text/html", "application/hal+json", "application/vnd.siren+json", "application/vnd.collection+json" ]
 						},
 						{ 
 							"id": "add", 
 							"name": "links",
 							"rel": [ "add" ], 
-							"url": "/tasks/", 
+							"url": "/tasks", 
 							"action": "append",
-							"model": "text={text}"
-						} 					
+							"model": "text={text}",
+							"sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json",
+							"accepting": [ "application/vnd.uber+json", "text/html" ]
+						},
+						{
+							"id": "metadata",
+							"name": "links",
+							"rel": [ "metadata" ],
+							"url": "/tasks/metadata",
+							"action": "read",
+							"accepting": [ "application/vnd.uber+json", "text/html" ]
+						},
+						{
+							"id": "events",
+							"name": "links",
+							"rel": [ "events" ],
+							"url": "/tasks/events",
+							"action": "read",
+							"accepting": [ "text/event-stream" ]
+						},
+						{
+							"id": "sync",
+							"name": "links",
+							"rel": [ "sync" ],
+							"url": "/tasks/sync{?cursor}",
+							"template": true,
+							"action": "read",
+							"accepting": [ "application/vnd.uber+json", "text/html" ]
+						}					
 					] 
 				},
 				{
@@ -162,7 +252,10 @@ var (
 							"rel": [ "item" ],
 							"data": 
 							[
-								{ "rel": [ "complete" ], "url": "/tasks/complete/", "action": "append", "model": "id=task1"},
+								{ "rel": [ "self" ], "url": "/tasks/task1", "action": "read", "accepting": [ "application/vnd.uber+json", "application/json", "text/html", "application/hal+json", "application/vnd.siren+json", "application/vnd.collection+json" ]},
+								{ "rel": [ "complete" ], "url": "/tasks/complete", "action": "append", "model": "id=task1", "sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json", "accepting": [ "application/vnd.uber+json", "text/html" ]},
+								{ "rel": [ "edit" ], "url": "/tasks/edit", "action": "replace", "model": "id=task1\u0026text={text}", "sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json", "accepting": [ "application/vnd.uber+json", "text/html" ]},
+								{ "rel": [ "move" ], "url": "/tasks/move", "action": "replace", "model": "id=task1\u0026position={position}", "sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json", "accepting": [ "application/vnd.uber+json", "text/html" ]},
 								{ "name": "text", "value": "task one" }
 							]
 						},
@@ -172,7 +265,10 @@ var (
 							"rel": [ "item" ],
 							"data": 
 							[
-								{ "rel": [ "complete" ], "url": "/tasks/complete/", "action": "append", "model": "id=task2"},
+								{ "rel": [ "self" ], "url": "/tasks/task2", "action": "read", "accepting": [ "application/vnd.uber+json", "application/json", "text/html", "application/hal+json", "application/vnd.siren+json", "application/vnd.collection+json" ]},
+								{ "rel": [ "complete" ], "url": "/tasks/complete", "action": "append", "model": "id=task2", "sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json", "accepting": [ "application/vnd.uber+json", "text/html" ]},
+								{ "rel": [ "edit" ], "url": "/tasks/edit", "action": "replace", "model": "id=task2\u0026text={text}", "sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json", "accepting": [ "application/vnd.uber+json", "text/html" ]},
+								{ "rel": [ "move" ], "url": "/tasks/move", "action": "replace", "model": "id=task2\u0026position={position}", "sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json", "accepting": [ "application/vnd.uber+json", "text/html" ]},
 								{ "name": "text", "value": "task two" }
 							]
 						},
@@ -182,7 +278,10 @@ var (
 							"rel": [ "item" ],
 							"data": 
 							[
-								{ "rel": [ "complete" ], "url": "/tasks/complete/", "action": "append", "model": "id=task3"},
+								{ "rel": [ "self" ], "url": "/tasks/task3", "action": "read", "accepting": [ "application/vnd.uber+json", "application/json", "text/html", "application/hal+json", "application/vnd.siren+json", "application/vnd.collection+json" ]},
+								{ "rel": [ "complete" ], "url": "/tasks/complete", "action": "append", "model": "id=task3", "sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json", "accepting": [ "application/vnd.uber+json", "text/html" ]},
+								{ "rel": [ "edit" ], "url": "/tasks/edit", "action": "replace", "model": "id=task3\u0026text={text}", "sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json", "accepting": [ "application/vnd.uber+json", "text/html" ]},
+								{ "rel": [ "move" ], "url": "/tasks/move", "action": "replace", "model": "id=task3\u0026position={position}", "sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json", "accepting": [ "application/vnd.uber+json", "text/html" ]},
 								{ "name": "text", "value": "task three" }
 							]
 						}
@@ -212,25 +311,54 @@ var (
 							"id": "list", 
 							"name": "links",
 							"rel": [ "collection" ], 
-							"url": "/tasks/", 
-							"action": "read" 
+							"url": "/tasks", 
+							"action": "read",
+							"accepting": [ "application/vnd.uber+json", "application/json", "text/html", "application/hal+json", "application/vnd.siren+json", "application/vnd.collection+json" ]
 						},
 						{ 
 							"id": "search", 
 							"name": "links",
 							"rel": [ "search" ], 
-							"url": "/tasks/search", 
+							"url": "/tasks/search{?text}", 
+							"template": true,
 							"action": "read",
-							"model": "?text={text}"
+							"accepting": [ "application/vnd.uber+json", "application/json", "text/html", "application/hal+json", "application/vnd.siren+json", "application/vnd.collection+json" ]
 						},
 						{ 
 							"id": "add", 
 							"name": "links",
 							"rel": [ "add" ], 
-							"url": "/tasks/", 
+							"url": "/tasks", 
 							"action": "append",
-							"model": "text={text}"
-						} 
+							"model": "text={text}",
+							"sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json",
+							"accepting": [ "application/vnd.uber+json", "text/html" ]
+						},
+						{
+							"id": "metadata",
+							"name": "links",
+							"rel": [ "metadata" ],
+							"url": "/tasks/metadata",
+							"action": "read",
+							"accepting": [ "application/vnd.uber+json", "text/html" ]
+						},
+						{
+							"id": "events",
+							"name": "links",
+							"rel": [ "events" ],
+							"url": "/tasks/events",
+							"action": "read",
+							"accepting": [ "text/event-stream" ]
+						},
+						{
+							"id": "sync",
+							"name": "links",
+							"rel": [ "sync" ],
+							"url": "/tasks/sync{?cursor}",
+							"template": true,
+							"action": "read",
+							"accepting": [ "application/vnd.uber+json", "text/html" ]
+						}
 					] 
 				},
 				{
@@ -238,12 +366,15 @@ var (
 					"data": 
 					[
 						{
-							"id": "task1",
+							"id": "task2",
 							"name": "tasks",
 							"rel": [ "item" ],
 							"data": 
 							[
-								{ "rel": [ "complete" ], "url": "/tasks/complete/", "action": "append", "model": "id=task1"},
+								{ "rel": [ "self" ], "url": "/tasks/task2", "action": "read", "accepting": [ "application/vnd.uber+json", "application/json", "text/html", "application/hal+json", "application/vnd.siren+json", "application/vnd.collection+json" ]},
+								{ "rel": [ "complete" ], "url": "/tasks/complete", "action": "append", "model": "id=task2", "sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json", "accepting": [ "application/vnd.uber+json", "text/html" ]},
+								{ "rel": [ "edit" ], "url": "/tasks/edit", "action": "replace", "model": "id=task2\u0026text={text}", "sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json", "accepting": [ "application/vnd.uber+json", "text/html" ]},
+								{ "rel": [ "move" ], "url": "/tasks/move", "action": "replace", "model": "id=task2\u0026position={position}", "sending": "application/x-www-form-urlencoded application/json application/vnd.uber+json", "accepting": [ "application/vnd.uber+json", "text/html" ]},
 								{ "name": "text", "value": "task two" }
 							]
 						}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/uber-apps/tasks/uber"
+)
+
+// maxTextLength is the longest task text taskd accepts, in characters.
+const maxTextLength = 500
+
+// addCommand is the decoded body of an add request.
+type addCommand struct {
+	text string
+}
+
+// completeCommand is the decoded body of a complete request. The task is named by its
+// position in the list, counting from 1.
+type completeCommand struct {
+	id       string
+	position int
+}
+
+// editCommand is the decoded body of an edit request.
+type editCommand struct {
+	id       string
+	position int
+	text     string
+}
+
+// moveCommand is the decoded body of a move request.
+type moveCommand struct {
+	id       string
+	position int
+	to       int
+}
+
+var taskidRE = regexp.MustCompile(`^task([1-9][0-9]*)$`)
+
+// decodeArgs reads the named arguments from the request body. The body is decoded according
+// to its Content-Type: application/x-www-form-urlencoded (the default), a JSON object whose
+// properties are the arguments, or an Uber document whose data elements carry the arguments
+// as named values.
+func decodeArgs(req *http.Request, names ...string) (map[string]string, *uber.Error) {
+	body, e := readBody(req)
+	if e != nil {
+		return nil, e
+	}
+
+	mt := formType
+	if ct := req.Header.Get("Content-Type"); len(ct) > 0 {
+		var err error
+		if mt, _, err = mime.ParseMediaType(ct); err != nil {
+			return nil, newError(errUnsupportedMediaType, fmt.Sprintf("Malformed Content-Type %q", ct))
+		}
+	}
+
+	args := map[string]string{}
+
+	switch mt {
+	case formType:
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, newError(errInvalidBody, "Malformed form body")
+		}
+		for _, n := range names {
+			if vs, ok := values[n]; ok {
+				args[n] = vs[0]
+			}
+		}
+
+	case jsonType:
+		var obj map[string]interface{}
+		if err := json.Unmarshal(body, &obj); err != nil {
+			return nil, newError(errInvalidBody, "Malformed JSON body, expected an object")
+		}
+		for _, n := range names {
+			v, ok := obj[n]
+			if !ok {
+				continue
+			}
+			s, ok := v.(string)
+			if !ok {
+				return nil, newError(errInvalidBody, "Invalid JSON body",
+					uber.FieldError{Field: n, Code: fieldInvalid, Message: fmt.Sprintf("%s must be a string", n)})
+			}
+			args[n] = s
+		}
+
+	case uberType:
+		ud, err := uber.Parse(bytes.NewReader(body))
+		if err != nil {
+			return nil, newError(errInvalidBody, "Malformed Uber body")
+		}
+		for _, n := range names {
+			if ds := ud.FindByName(n); len(ds) > 0 {
+				args[n] = ds[0].Value
+			}
+		}
+
+	default:
+		return nil, newError(errUnsupportedMediaType, fmt.Sprintf("Cannot decode a %s body", mt))
+	}
+
+	return args, nil
+}
+
+// limitBody refuses requests whose bodies are larger than max bytes. Those that say how large
+// they are up front are refused at once, the others when reading them goes past max.
+func limitBody(max int64, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.ContentLength > max {
+			writeError(w, req, newError(errBodyTooLarge, fmt.Sprintf("Request bodies may be at most %d bytes", max)))
+			return
+		}
+		if req.Body != nil {
+			req.Body = http.MaxBytesReader(w, req.Body, max)
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// readBody reads the body of the request.
+func readBody(req *http.Request) ([]byte, *uber.Error) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, newError(errBodyTooLarge, fmt.Sprintf("Request bodies may be at most %d bytes", tooLarge.Limit))
+		}
+		return nil, newError(errBodyUnreadable, "Cannot read HTTP request body")
+	}
+	return body, nil
+}
+
+// sends restricts h to requests whose body is in one of the media types t declares it
+// accepts. Other requests are refused with an unsupported_media_type error. A request without
+// a Content-Type is taken to be a form, as decodeArgs does.
+func sends(t transition, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ct := req.Header.Get("Content-Type")
+		if len(ct) == 0 {
+			ct = formType
+		}
+
+		mt, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			writeError(w, req, newError(errUnsupportedMediaType, fmt.Sprintf("Malformed Content-Type %q", ct)))
+			return
+		}
+
+		for _, s := range t.sending {
+			if s == mt {
+				h.ServeHTTP(w, req)
+				return
+			}
+		}
+
+		writeError(w, req, newError(errUnsupportedMediaType,
+			fmt.Sprintf("The %s transition does not accept %s, send one of %s", t.id, mt, strings.Join(t.sending, ", "))))
+	})
+}
+
+// decodeAdd decodes and validates the body of an add request.
+func decodeAdd(req *http.Request) (addCommand, *uber.Error) {
+	args, e := decodeArgs(req, "text")
+	if e != nil {
+		return addCommand{}, e
+	}
+
+	text, e := decodeText(args, "Invalid add task body")
+	if e != nil {
+		return addCommand{}, e
+	}
+
+	return addCommand{text: text}, nil
+}
+
+// decodeComplete decodes and validates the body of a complete request.
+func decodeComplete(req *http.Request) (completeCommand, *uber.Error) {
+	args, e := decodeArgs(req, "id")
+	if e != nil {
+		return completeCommand{}, e
+	}
+
+	id, position, e := decodeTaskID(args, "Invalid complete task body")
+	if e != nil {
+		return completeCommand{}, e
+	}
+
+	return completeCommand{id: id, position: position}, nil
+}
+
+// decodeEdit decodes and validates the body of an edit request.
+func decodeEdit(req *http.Request) (editCommand, *uber.Error) {
+	args, e := decodeArgs(req, "id", "text")
+	if e != nil {
+		return editCommand{}, e
+	}
+
+	id, position, e := decodeTaskID(args, "Invalid edit task body")
+	if e != nil {
+		return editCommand{}, e
+	}
+
+	text, e := decodeText(args, "Invalid edit task body")
+	if e != nil {
+		return editCommand{}, e
+	}
+
+	return editCommand{id: id, position: position, text: text}, nil
+}
+
+// decodeMove decodes and validates the body of a move request.
+func decodeMove(req *http.Request) (moveCommand, *uber.Error) {
+	args, e := decodeArgs(req, "id", "position")
+	if e != nil {
+		return moveCommand{}, e
+	}
+
+	id, position, e := decodeTaskID(args, "Invalid move task body")
+	if e != nil {
+		return moveCommand{}, e
+	}
+
+	p := strings.TrimSpace(args["position"])
+	if len(p) == 0 {
+		return moveCommand{}, newError(errInvalidBody, "Invalid move task body",
+			uber.FieldError{Field: "position", Code: fieldRequired, Message: "The position to move the task to is required"})
+	}
+	to, err := strconv.Atoi(p)
+	if err != nil || to < 1 {
+		return moveCommand{}, newError(errInvalidBody, "Invalid move task body",
+			uber.FieldError{Field: "position", Code: fieldInvalid, Message: fmt.Sprintf("%q is not a position in the list", p)})
+	}
+
+	return moveCommand{id: id, position: position, to: to}, nil
+}
+
+// decodeText validates the text argument of a request. Errors are reported with message.
+func decodeText(args map[string]string, message string) (string, *uber.Error) {
+	text := strings.TrimSpace(args["text"])
+	switch {
+	case len(text) == 0:
+		return "", newError(errInvalidBody, message,
+			uber.FieldError{Field: "text", Code: fieldRequired, Message: "The text of the task is required"})
+	case !utf8.ValidString(text):
+		return "", newError(errInvalidBody, message,
+			uber.FieldError{Field: "text", Code: fieldInvalid, Message: "The text of the task must be UTF-8"})
+	case utf8.RuneCountInString(text) > maxTextLength:
+		return "", newError(errInvalidBody, message,
+			uber.FieldError{Field: "text", Code: fieldInvalid, Message: fmt.Sprintf("The text of the task must be at most %d characters", maxTextLength)})
+	}
+
+	return text, nil
+}
+
+// decodeTaskID validates the id argument of a request and returns the position in the list
+// of the task it names. Errors are reported with message.
+func decodeTaskID(args map[string]string, message string) (string, int, *uber.Error) {
+	id := strings.TrimSpace(args["id"])
+	if len(id) == 0 {
+		return "", 0, newError(errInvalidBody, message,
+			uber.FieldError{Field: "id", Code: fieldRequired, Message: "The id of the task is required"})
+	}
+
+	sm := taskidRE.FindStringSubmatch(id)
+	if sm == nil {
+		return "", 0, newError(errInvalidBody, message,
+			uber.FieldError{Field: "id", Code: fieldInvalid, Message: fmt.Sprintf("%q is not a task id", id)})
+	}
+
+	position, err := strconv.Atoi(sm[1])
+	if err != nil {
+		return "", 0, newError(errInvalidBody, message,
+			uber.FieldError{Field: "id", Code: fieldInvalid, Message: fmt.Sprintf("%q is not a task id", id)})
+	}
+
+	return id, position, nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/uber-apps/tasks/uber"
+)
+
+func TestDecodeAdd(t *testing.T) {
+	tt := []struct {
+		description string
+		ct          string
+		payload     string
+		text        string
+		code        string
+		field       string
+	}{
+		{"form without content type", "", "text=another task", "another task", "", ""},
+		{"form encoded ampersand", formType, "text=buy+eggs+%26+milk", "buy eggs & milk", "", ""},
+		{"form encoded accents", formType + "; charset=utf-8", "text=caf%C3%A9+cr%C3%A8me", "café crème", "", ""},
+		{"form encoded plus", formType, "text=1%2B1", "1+1", "", ""},
+		{"form missing text", formType, "task=another task", "", errInvalidBody, "text"},
+		{"form blank text", formType, "text=+++", "", errInvalidBody, "text"},
+		{"form text too long", formType, "text=" + strings.Repeat("x", maxTextLength+1), "", errInvalidBody, "text"},
+		{"json", jsonType, `{"text":"buy eggs & milk"}`, "buy eggs & milk", "", ""},
+		{"json wrong type", jsonType, `{"text":42}`, "", errInvalidBody, "text"},
+		{"json malformed", jsonType, `{"text":`, "", errInvalidBody, ""},
+		{"uber", uberType, `{"uber":{"version":"1.0","data":[{"name":"text","value":"café"}]}}`, "café", "", ""},
+		{"uber missing text", uberType, `{"uber":{"version":"1.0","data":[]}}`, "", errInvalidBody, "text"},
+		{"unsupported type", "text/plain", "another task", "", errUnsupportedMediaType, ""},
+	}
+
+	for _, tst := range tt {
+		req, err := http.NewRequest(POST, "/tasks", strings.NewReader(tst.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tst.ct) > 0 {
+			req.Header.Set("Content-Type", tst.ct)
+		}
+
+		cmd, e := decodeAdd(req)
+		if len(tst.code) == 0 {
+			if e != nil {
+				t.Errorf("%s: unexpected error %v", tst.description, e)
+			} else if cmd.text != tst.text {
+				t.Errorf("%s: expected text %q, got %q", tst.description, tst.text, cmd.text)
+			}
+			continue
+		}
+
+		if e == nil || e.Code != tst.code {
+			t.Errorf("%s: expected error %s, got %v", tst.description, tst.code, e)
+			continue
+		}
+
+		if len(tst.field) > 0 && (len(e.Fields) != 1 || e.Fields[0].Field != tst.field) {
+			t.Errorf("%s: expected a field error for %s, got %+v", tst.description, tst.field, e.Fields)
+		}
+	}
+}
+
+func TestDecodeComplete(t *testing.T) {
+	tt := []struct {
+		description string
+		ct          string
+		payload     string
+		position    int
+		code        string
+	}{
+		{"form", formType, "id=task2", 2, ""},
+		{"json", jsonType, `{"id":"task12"}`, 12, ""},
+		{"uber", uberType, `{"uber":{"version":"1.0","data":[{"name":"id","value":"task3"}]}}`, 3, ""},
+		{"missing id", formType, "task=task4", 0, errInvalidBody},
+		{"malformed id", formType, "id=item4", 0, errInvalidBody},
+		{"zero id", formType, "id=task0", 0, errInvalidBody},
+	}
+
+	for _, tst := range tt {
+		req, err := http.NewRequest(POST, "/tasks/complete", strings.NewReader(tst.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", tst.ct)
+
+		cmd, e := decodeComplete(req)
+		switch {
+		case len(tst.code) == 0 && e != nil:
+			t.Errorf("%s: unexpected error %v", tst.description, e)
+		case len(tst.code) == 0 && cmd.position != tst.position:
+			t.Errorf("%s: expected position %d, got %d", tst.description, tst.position, cmd.position)
+		case len(tst.code) > 0 && (e == nil || e.Code != tst.code):
+			t.Errorf("%s: expected error %s, got %v", tst.description, tst.code, e)
+		}
+	}
+}
+
+func TestSends(t *testing.T) {
+	tt := []struct {
+		description string
+		url         string
+		ct          string
+		payload     string
+		status      int
+	}{
+		{"add without content type", "/tasks", "", "text=another task", http.StatusNoContent},
+		{"add form", "/tasks", formType + "; charset=utf-8", "text=another task", http.StatusNoContent},
+		{"add json", "/tasks", jsonType, `{"text":"another task"}`, http.StatusNoContent},
+		{"add plain text", "/tasks", "text/plain", "another task", http.StatusUnsupportedMediaType},
+		{"add malformed content type", "/tasks", "text/", "another task", http.StatusUnsupportedMediaType},
+		{"complete xml", "/tasks/complete", "application/xml", "<id>task1</id>", http.StatusUnsupportedMediaType},
+	}
+
+	for _, tst := range tt {
+		usecontext(t, onetask())
+
+		req, err := http.NewRequest(POST, tst.url, strings.NewReader(tst.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tst.ct) > 0 {
+			req.Header.Set("Content-Type", tst.ct)
+		}
+
+		w := httptest.NewRecorder()
+		router().ServeHTTP(w, req)
+
+		if w.Code != tst.status {
+			t.Errorf("%s: expected status %d, got %d", tst.description, tst.status, w.Code)
+			continue
+		}
+
+		if tst.status == http.StatusUnsupportedMediaType {
+			ud, err := uber.Parse(w.Body)
+			if err != nil {
+				t.Errorf("%s: %v", tst.description, err)
+				continue
+			}
+			if e := uber.ErrorFromDoc(ud); e == nil || e.Code != errUnsupportedMediaType {
+				t.Errorf("%s: expected error %s, got %v", tst.description, errUnsupportedMediaType, e)
+			}
+		}
+	}
+}
+
+func TestDecodeEdit(t *testing.T) {
+	tt := []struct {
+		description string
+		ct          string
+		payload     string
+		position    int
+		text        string
+		code        string
+		field       string
+	}{
+		{"form", formType, "id=task2&text=buy+eggs", 2, "buy eggs", "", ""},
+		{"json", jsonType, `{"id":"task1","text":"café"}`, 1, "café", "", ""},
+		{"missing text", formType, "id=task2", 0, "", errInvalidBody, "text"},
+		{"missing id", formType, "text=buy+eggs", 0, "", errInvalidBody, "id"},
+		{"malformed id", jsonType, `{"id":"item1","text":"buy eggs"}`, 0, "", errInvalidBody, "id"},
+	}
+
+	for _, tst := range tt {
+		req, err := http.NewRequest(PUT, "/tasks/edit", strings.NewReader(tst.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", tst.ct)
+
+		cmd, e := decodeEdit(req)
+		switch {
+		case len(tst.code) == 0 && e != nil:
+			t.Errorf("%s: unexpected error %v", tst.description, e)
+		case len(tst.code) == 0 && (cmd.position != tst.position || cmd.text != tst.text):
+			t.Errorf("%s: expected task%d %q, got task%d %q", tst.description, tst.position, tst.text, cmd.position, cmd.text)
+		case len(tst.code) > 0 && (e == nil || e.Code != tst.code):
+			t.Errorf("%s: expected error %s, got %v", tst.description, tst.code, e)
+		case len(tst.code) > 0 && (len(e.Fields) != 1 || e.Fields[0].Field != tst.field):
+			t.Errorf("%s: expected a field error for %s, got %+v", tst.description, tst.field, e.Fields)
+		}
+	}
+}
+
+func TestDecodeMove(t *testing.T) {
+	tt := []struct {
+		description string
+		ct          string
+		payload     string
+		position    int
+		to          int
+		code        string
+		field       string
+	}{
+		{"form", formType, "id=task2&position=1", 2, 1, "", ""},
+		{"json", jsonType, `{"id":"task1","position":"3"}`, 1, 3, "", ""},
+		{"missing position", formType, "id=task2", 0, 0, errInvalidBody, "position"},
+		{"position zero", formType, "id=task2&position=0", 0, 0, errInvalidBody, "position"},
+		{"position not a number", formType, "id=task2&position=top", 0, 0, errInvalidBody, "position"},
+		{"missing id", formType, "position=1", 0, 0, errInvalidBody, "id"},
+	}
+
+	for _, tst := range tt {
+		req, err := http.NewRequest(PUT, "/tasks/move", strings.NewReader(tst.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", tst.ct)
+
+		cmd, e := decodeMove(req)
+		switch {
+		case len(tst.code) == 0 && e != nil:
+			t.Errorf("%s: unexpected error %v", tst.description, e)
+		case len(tst.code) == 0 && (cmd.position != tst.position || cmd.to != tst.to):
+			t.Errorf("%s: expected task%d to %d, got task%d to %d", tst.description, tst.position, tst.to, cmd.position, cmd.to)
+		case len(tst.code) > 0 && (e == nil || e.Code != tst.code):
+			t.Errorf("%s: expected error %s, got %v", tst.description, tst.code, e)
+		case len(tst.code) > 0 && (len(e.Fields) != 1 || e.Fields[0].Field != tst.field):
+			t.Errorf("%s: expected a field error for %s, got %+v", tst.description, tst.field, e.Fields)
+		}
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	usecontext(t, notasks())
+	h := limitBody(16, router())
+
+	tt := []struct {
+		description string
+		payload     string
+		chunked     bool
+		rc          int
+		code        string
+	}{
+		{"within the limit", "text=task+one", false, 204, ""},
+		{"too large", "text=" + strings.Repeat("x", 16), false, 413, errBodyTooLarge},
+		{"too large without a length", "text=" + strings.Repeat("x", 16), true, 413, errBodyTooLarge},
+	}
+
+	for _, tst := range tt {
+		req, err := http.NewRequest(POST, "/tasks", strings.NewReader(tst.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", formType)
+		if tst.chunked {
+			req.ContentLength = -1
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != tst.rc {
+			t.Errorf("%s: expected status %d, got %d: %s", tst.description, tst.rc, w.Code, w.Body.String())
+			continue
+		}
+		if len(tst.code) == 0 {
+			continue
+		}
+
+		ud, err := uber.Parse(w.Body)
+		if err != nil {
+			t.Errorf("%s: %v", tst.description, err)
+			continue
+		}
+		if e := uber.ErrorFromDoc(ud); e == nil || e.Code != tst.code {
+			t.Errorf("%s: expected error %s, got %v", tst.description, tst.code, e)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/github.com/gorilla/mux"
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/golang.org/x/net/context"
+	"github.com/uber-apps/tasks/uber"
+)
+
+// errorsURL is where the documentation of taskd's error codes is served. Every error links
+// to the entry for its code.
+const errorsURL = "/errors"
+
+// The stable error codes taskd reports. Clients should branch on these rather than on the
+// accompanying messages, which may change.
+const (
+	errBodyUnreadable        = "body_unreadable"
+	errBodyTooLarge          = "body_too_large"
+	errInvalidBody           = "invalid_body"
+	errUnsupportedMediaType  = "unsupported_media_type"
+	errMissingParameter      = "missing_parameter"
+	errNotFound              = "not_found"
+	errMethodNotAllowed      = "method_not_allowed"
+	errTemplateMismatch      = "template_mismatch"
+	errTaskNotFound          = "task_not_found"
+	errWebhookNotFound       = "webhook_not_found"
+	errConflictNotFound      = "conflict_not_found"
+	errTooManyConflicts      = "too_many_conflicts"
+	errPreconditionFailed    = "precondition_failed"
+	errInvalidCursor         = "invalid_cursor"
+	errInvalidIdempotencyKey = "invalid_idempotency_key"
+	errIdempotencyKeyReused  = "idempotency_key_reused"
+	errIdempotencyKeyInUse   = "idempotency_key_in_use"
+	errNotAcceptable         = "not_acceptable"
+	errEncodingFailed        = "encoding_failed"
+	errAssetUnavailable      = "asset_unavailable"
+	errStreamingUnsupported  = "streaming_unsupported"
+)
+
+// Codes describing problems with individual fields of a request.
+const (
+	fieldRequired = "required"
+	fieldInvalid  = "invalid"
+)
+
+// errorcode documents one of taskd's error codes.
+type errorcode struct {
+	code   string
+	status int
+	doc    string
+}
+
+var errorcodes = []errorcode{
+	{errBodyUnreadable, http.StatusInternalServerError, "The body of the request could not be read."},
+	{errBodyTooLarge, http.StatusRequestEntityTooLarge, "The body of the request is larger than taskd's max-body setting allows."},
+	{errInvalidBody, http.StatusBadRequest, "The body of the request is malformed or fails validation. The field errors say which arguments are at fault."},
+	{errUnsupportedMediaType, http.StatusUnsupportedMediaType, "The body of the request is in a format the transition does not accept."},
+	{errMissingParameter, http.StatusBadRequest, "A required query parameter is missing. The field errors name it."},
+	{errNotFound, http.StatusNotFound, "There is nothing at the request URL. Start again from the task list."},
+	{errMethodNotAllowed, http.StatusMethodNotAllowed, "The request URL does not support the request method. The Allow header lists the methods it does support."},
+	{errTemplateMismatch, http.StatusNotFound, "The request URL does not match the URI template advertised for the transition."},
+	{errTaskNotFound, http.StatusNotFound, "The task named by the request does not exist."},
+	{errWebhookNotFound, http.StatusNotFound, "The webhook subscription named by the request does not exist."},
+	{errConflictNotFound, http.StatusNotFound, "The conflict named by the request does not exist or has already been resolved."},
+	{errTooManyConflicts, http.StatusConflict, "Too many conflicts found by earlier merges are unresolved. Resolve some and retry the merge."},
+	{errInvalidCursor, http.StatusBadRequest, "The sync cursor was not issued by taskd. Sync without a cursor to start again."},
+	{errPreconditionFailed, http.StatusPreconditionFailed, "The If-Match header names a version of the task list or task that is no longer current. Read it again and retry."},
+	{errInvalidIdempotencyKey, http.StatusBadRequest, "The Idempotency-Key header is malformed or longer than 255 characters."},
+	{errIdempotencyKeyReused, http.StatusUnprocessableEntity, "The Idempotency-Key was already used for a request with a different body. Use a new key for a new request."},
+	{errIdempotencyKeyInUse, http.StatusConflict, "The request with the same Idempotency-Key is still being handled. Retry later to get its response."},
+	{errNotAcceptable, http.StatusNotAcceptable, "None of the media types named by the Accept header can be produced."},
+	{errEncodingFailed, http.StatusInternalServerError, "The response could not be encoded."},
+	{errAssetUnavailable, http.StatusInternalServerError, "A file of the browser client could not be read."},
+	{errStreamingUnsupported, http.StatusInternalServerError, "The change feed could not be streamed over the connection."},
+}
+
+// newError creates the error for code. Its status is the one documented for the code.
+func newError(code, message string, fields ...uber.FieldError) *uber.Error {
+	e := &uber.Error{Code: code, Status: http.StatusInternalServerError, Message: message, Fields: fields, Help: errorsURL + "#" + code}
+	for _, ec := range errorcodes {
+		if ec.code == code {
+			e.Status = ec.status
+		}
+	}
+	return e
+}
+
+// writeError responds with an Uber document describing the error, rendered as an HTML page
+// for clients that prefer HTML.
+func writeError(w http.ResponseWriter, req *http.Request, e *uber.Error) {
+	writeDoc(w, req, e.Status, e.Doc())
+}
+
+// notFound responds to the requests r has no route for. If r has routes for the request's
+// path, but not its method, the response is 405 Method Not Allowed with an Allow header
+// listing the methods it does have. The error links back to the task list so clients can
+// find their way again.
+func notFound(r *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		e := newError(errNotFound, fmt.Sprintf("No resource at %s", req.URL.Path))
+		if methods := methodsFor(r, req); len(methods) > 0 {
+			w.Header().Set("Allow", strings.Join(append(methods, "OPTIONS"), ", "))
+			e = newError(errMethodNotAllowed, fmt.Sprintf("%s does not support %s", req.URL.Path, req.Method))
+		}
+
+		ud := e.Doc()
+		ud.Uber.Error = append(ud.Uber.Error, transitions["list"].link().Build())
+		writeDoc(w, req, e.Status, ud)
+	})
+}
+
+// errordocs responds with the documentation of taskd's error codes.
+func errordocs(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	codes := uber.NewData().ID("errors")
+	for _, ec := range errorcodes {
+		codes.Append(uber.NewData().ID(ec.code).Name("errorcode").Append(
+			uber.NewData().Name("status").Value(fmt.Sprintf("%d %s", ec.status, http.StatusText(ec.status))),
+			uber.NewData().Name("description").Value(ec.doc)))
+	}
+
+	writeDoc(w, req, http.StatusOK, uber.NewDoc().Data(codes).Build())
+}
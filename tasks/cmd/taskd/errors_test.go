@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/uber-apps/tasks/uber"
+)
+
+func TestErrorCodes(t *testing.T) {
+	tt := []struct {
+		description string
+		hfn         ContextHandlerFunc
+		req         string
+		method      string
+		payload     string
+		code        string
+		field       string
+	}{
+		{"bad add request", taskadd, "/tasks", POST, "task=another task", errInvalidBody, "text"},
+		{"bad complete request", taskcomplete, "/tasks/complete", POST, "task=task4", errInvalidBody, "id"},
+		{"complete unknown task", taskcomplete, "/tasks/complete", POST, "id=task9", errTaskNotFound, ""},
+		{"bad search request", tasksearch, "/tasks/search", GET, "", errMissingParameter, "text"},
+		{"search outside template", tasksearch, "/tasks?text=x", GET, "", errTemplateMismatch, ""},
+	}
+
+	for _, tst := range tt {
+		req, err := http.NewRequest(tst.method, tst.req, strings.NewReader(tst.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		tst.hfn(multipletasks(), w, req)
+
+		var ud uber.Doc
+		if err := json.Unmarshal(w.Body.Bytes(), &ud); err != nil {
+			t.Errorf("%s: cannot decode response: %v", tst.description, err)
+			continue
+		}
+
+		e := uber.ErrorFromDoc(&ud)
+		if e == nil {
+			t.Errorf("%s: response has no error section: %s", tst.description, w.Body.String())
+			continue
+		}
+
+		if e.Code != tst.code || e.Status != w.Code {
+			t.Errorf("%s: expected code %s with status %d, got %s with status %d", tst.description, tst.code, w.Code, e.Code, e.Status)
+		}
+
+		if e.Help != errorsURL+"#"+tst.code {
+			t.Errorf("%s: unexpected help link %q", tst.description, e.Help)
+		}
+
+		if len(tst.field) > 0 && (len(e.Fields) != 1 || e.Fields[0].Field != tst.field) {
+			t.Errorf("%s: expected a field error for %s, got %+v", tst.description, tst.field, e.Fields)
+		}
+	}
+}
+
+func TestErrorDocs(t *testing.T) {
+	req, err := http.NewRequest(GET, errorsURL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router().ServeHTTP(w, req)
+
+	var ud uber.Doc
+	if err := json.Unmarshal(w.Body.Bytes(), &ud); err != nil {
+		t.Fatalf("cannot decode error documentation: %v", err)
+	}
+
+	for _, ec := range errorcodes {
+		if ud.FindByID(ec.code) == nil {
+			t.Errorf("error code %s is not documented", ec.code)
+		}
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	tt := []struct {
+		description string
+		method      string
+		url         string
+		rc          int
+		code        string
+		allow       string
+	}{
+		{"unknown path", GET, "/nowhere", 404, errNotFound, ""},
+		{"unknown task path", GET, "/tasks/item4", 404, errNotFound, ""},
+		{"delete the list", "DELETE", "/tasks", 405, errMethodNotAllowed, "GET, POST, OPTIONS"},
+		{"read complete", GET, "/tasks/complete", 405, errMethodNotAllowed, "POST, OPTIONS"},
+		{"post to a task", POST, "/tasks/task1", 405, errMethodNotAllowed, "GET, OPTIONS"},
+	}
+
+	for _, tst := range tt {
+		usecontext(t, onetask())
+
+		req, err := http.NewRequest(tst.method, tst.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", uberType)
+
+		w := httptest.NewRecorder()
+		router().ServeHTTP(w, req)
+
+		if w.Code != tst.rc {
+			t.Errorf("%s: expected status %d, got %d", tst.description, tst.rc, w.Code)
+			continue
+		}
+		if allow := w.Header().Get("Allow"); allow != tst.allow {
+			t.Errorf("%s: expected Allow %q, got %q", tst.description, tst.allow, allow)
+		}
+		if ct := w.Header().Get("Content-Type"); ct != uberType {
+			t.Errorf("%s: expected an Uber document, got %s", tst.description, ct)
+		}
+
+		ud, err := uber.Parse(w.Body)
+		if err != nil {
+			t.Errorf("%s: %v", tst.description, err)
+			continue
+		}
+		if errs := uber.Validate(ud); len(errs) > 0 {
+			t.Errorf("%s: invalid error document: %v", tst.description, errs)
+		}
+		if e := uber.ErrorFromDoc(ud); e == nil || e.Code != tst.code {
+			t.Errorf("%s: expected error %s, got %v", tst.description, tst.code, e)
+		}
+		linked := false
+		for _, d := range ud.Uber.Error {
+			if d.HasRel("collection") && d.URL == transitions["list"].href() {
+				linked = true
+			}
+		}
+		if !linked {
+			t.Errorf("%s: expected a link to the task list", tst.description)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/golang.org/x/net/context"
+	"github.com/uber-apps/tasks/uber"
+)
+
+const (
+	eventStreamType = "text/event-stream"
+
+	// eventBufferSize is how many of the most recent events the feed keeps for clients that
+	// reconnect with a Last-Event-ID header.
+	eventBufferSize = 256
+
+	// watcherBacklog is how many events may be waiting to be sent to a client. Clients that
+	// fall further behind are disconnected, and resume from the buffer when they reconnect.
+	watcherBacklog = 64
+)
+
+// eventKeepalive is how often a comment is sent on an idle stream so that proxies don't
+// close it.
+var eventKeepalive = 15 * time.Second
+
+// event is a change to the task list as sent on the change feed. Its id is the version of
+// the store the change produced and kind is the id of the transition that made it, or reset.
+// From is the id a moved task had before the move.
+type event struct {
+	id   int
+	kind string
+	task task
+	from string
+}
+
+// feed fans the changes made to a taskstore out to the clients watching the change feed.
+// Events carry the store's versions as their ids, so they are numbered without gaps.
+type feed struct {
+	mu       sync.Mutex
+	last     int
+	buffer   []event
+	watchers map[chan event]bool
+}
+
+func newFeed() *feed {
+	return &feed{watchers: map[chan event]bool{}}
+}
+
+// publish sends e to every watcher and keeps it in the buffer.
+func (f *feed) publish(e event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.last = e.id
+	f.buffer = append(f.buffer, e)
+	if len(f.buffer) > eventBufferSize {
+		f.buffer = f.buffer[len(f.buffer)-eventBufferSize:]
+	}
+
+	for ch := range f.watchers {
+		select {
+		case ch <- e:
+		default:
+			delete(f.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// watch registers a watcher for the events published from now on. When resuming it also
+// returns the buffered events that came after the one with id last. If some of those are no
+// longer buffered, or last is not an id the feed has issued, the result is a reset event
+// instead, telling the client to read the task list again.
+func (f *feed) watch(last int, resuming bool) ([]event, chan event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan event, watcherBacklog)
+	f.watchers[ch] = true
+
+	if !resuming {
+		return nil, ch
+	}
+	missed, ok := f.after(last)
+	if !ok {
+		return []event{resetEvent(f.last)}, ch
+	}
+	return missed, ch
+}
+
+// since returns the buffered events that came after the one with id last, and false if
+// some of them are no longer buffered or last is not an id the feed has issued.
+func (f *feed) since(last int) ([]event, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.after(last)
+}
+
+func (f *feed) after(last int) ([]event, bool) {
+	if last == f.last {
+		return nil, true
+	}
+	if last > f.last || last < 0 || len(f.buffer) == 0 || f.buffer[0].id > last+1 {
+		return nil, false
+	}
+
+	events := []event{}
+	for _, e := range f.buffer {
+		if e.id > last {
+			events = append(events, e)
+		}
+	}
+	return events, true
+}
+
+// unwatch removes a watcher registered by watch.
+func (f *feed) unwatch(ch chan event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.watchers[ch] {
+		delete(f.watchers, ch)
+		close(ch)
+	}
+}
+
+// resetEvent creates the event telling a client it missed changes and should read the task
+// list again.
+func resetEvent(version int) event {
+	return event{id: version, kind: "reset"}
+}
+
+// doc creates the Uber document sent as the event's data. It holds the affected task, with
+// its links unless it was completed since its id then names another task or none. Move
+// events also carry the id the task had before. Reset events link to the task list instead.
+func (e event) doc() *uber.Doc {
+	switch e.kind {
+	case "reset":
+		return uber.NewDoc().Data(transitions["list"].link()).Build()
+	case "complete":
+		return uber.NewDoc().Data(taskData(e.task.id, e.task.text, nil)).Build()
+	case "move":
+		return uber.NewDoc().Data(taskData(e.task.id, e.task.text, itemlinks).Append(uber.NewData().Name("from").Value(e.from))).Build()
+	default:
+		return uber.NewDoc().Data(taskData(e.task.id, e.task.text, itemlinks)).Build()
+	}
+}
+
+// write sends the event in the server-sent events format.
+func (e event) write(w http.ResponseWriter) error {
+	bs, err := json.Marshal(e.doc())
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.id, e.kind, bs)
+	return err
+}
+
+// lastEventID returns the id of the last event a reconnecting client saw, if it sent one.
+func lastEventID(req *http.Request) (int, bool) {
+	id, err := strconv.Atoi(strings.TrimSpace(req.Header.Get("Last-Event-ID")))
+	return id, err == nil
+}
+
+// taskevents streams the changes made to the task list as server-sent events, one for every
+// add, complete, edit and move, until the client goes away. Clients that reconnect with a
+// Last-Event-ID header are sent the events they missed first.
+func taskevents(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	if len(negotiate(req.Header.Get("Accept"), []string{eventStreamType})) == 0 {
+		writeError(w, req, newError(errNotAcceptable, "The change feed is only available as "+eventStreamType))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, req, newError(errStreamingUnsupported, "Cannot stream the change feed"))
+		return
+	}
+
+	tasks := ctx.Value("tasks").(*taskstore)
+	missed, ch := tasks.feed.watch(lastEventID(req))
+	defer tasks.feed.unwatch(ch)
+
+	w.Header().Set("Content-Type", eventStreamType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for _, e := range missed {
+		if e.write(w) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(eventKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case e, open := <-ch:
+			if !open || e.write(w) != nil {
+				return
+			}
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case <-req.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
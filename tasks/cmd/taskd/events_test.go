@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uber-apps/tasks/uber"
+)
+
+func TestFeedResume(t *testing.T) {
+	long := newStore()
+	for i := 0; i < eventBufferSize+2; i++ {
+		long.add(fmt.Sprintf("task %d", i), precondition{})
+	}
+
+	tt := []struct {
+		description string
+		store       *taskstore
+		last        int
+		resuming    bool
+		count       int
+		first       int
+		kind        string
+	}{
+		{"new watcher", edited(), 0, false, 0, 0, ""},
+		{"resume from the start", edited(), 0, true, 3, 1, "add"},
+		{"resume part way", edited(), 2, true, 1, 3, "edit"},
+		{"up to date", edited(), 3, true, 0, 0, ""},
+		{"unknown event", edited(), 9, true, 1, 3, "reset"},
+		{"events no longer buffered", long, 1, true, 1, eventBufferSize + 2, "reset"},
+		{"oldest buffered event", long, 2, true, eventBufferSize, 3, "add"},
+	}
+
+	for _, tst := range tt {
+		missed, ch := tst.store.feed.watch(tst.last, tst.resuming)
+		tst.store.feed.unwatch(ch)
+
+		if len(missed) != tst.count {
+			t.Errorf("%s: expected %d events, got %d", tst.description, tst.count, len(missed))
+			continue
+		}
+		if tst.count > 0 && (missed[0].id != tst.first || missed[0].kind != tst.kind) {
+			t.Errorf("%s: expected to start with %d %s, got %d %s", tst.description, tst.first, tst.kind, missed[0].id, missed[0].kind)
+		}
+	}
+}
+
+// edited returns a store holding two tasks, the second of which has been edited.
+func edited() *taskstore {
+	s := newStore("task one", "task two")
+	s.edit(2, "task 2", precondition{})
+	return s
+}
+
+func TestEventStream(t *testing.T) {
+	usecontext(t, onetask())
+	srv := httptest.NewServer(router())
+	defer srv.Close()
+
+	req, err := http.NewRequest(GET, srv.URL+transitions["events"].href(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", eventStreamType)
+	req.Header.Set("Last-Event-ID", "0")
+
+	hc := &http.Client{Timeout: 5 * time.Second}
+	resp, err := hc.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != eventStreamType {
+		t.Fatalf("expected an event stream, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	for _, change := range [][2]string{{"add", "text=task two"}, {"move", "id=task2&position=1"}, {"complete", "id=task1"}} {
+		r, err := hc.Post(srv.URL+transitions[change[0]].href(), formType, strings.NewReader(change[1]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Body.Close()
+	}
+
+	expected := []struct {
+		id   string
+		kind string
+		task string
+		text string
+		from string
+	}{
+		{"1", "add", "task1", "task one", ""},
+		{"2", "add", "task2", "task two", ""},
+		{"3", "move", "task1", "task two", "task2"},
+		{"4", "complete", "task1", "task two", ""},
+	}
+
+	s := bufio.NewScanner(resp.Body)
+	for _, want := range expected {
+		fields := map[string]string{}
+		for s.Scan() && len(s.Text()) > 0 {
+			kv := strings.SplitN(s.Text(), ": ", 2)
+			fields[kv[0]] = kv[1]
+		}
+		if err := s.Err(); err != nil {
+			t.Fatal(err)
+		}
+
+		if fields["id"] != want.id || fields["event"] != want.kind {
+			t.Errorf("expected event %s %s, got %s %s", want.id, want.kind, fields["id"], fields["event"])
+			continue
+		}
+
+		ud, err := uber.Parse(strings.NewReader(fields["data"]))
+		if err != nil {
+			t.Errorf("event %s: %v", want.id, err)
+			continue
+		}
+		item := ud.FindByID(want.task)
+		if item == nil || len(item.FindByName("text")) != 1 || item.FindByName("text")[0].Value != want.text {
+			t.Errorf("event %s: expected %s with text %q, got %+v", want.id, want.task, want.text, item)
+			continue
+		}
+		if links := item.FindByRel("edit"); (want.kind == "complete") != (len(links) == 0) {
+			t.Errorf("event %s: unexpected links %+v", want.id, links)
+		}
+		if from := item.FindByName("from"); len(want.from) > 0 && (len(from) != 1 || from[0].Value != want.from) {
+			t.Errorf("event %s: expected the task to have moved from %s, got %+v", want.id, want.from, from)
+		}
+	}
+}
+
+func TestEventStreamNotAcceptable(t *testing.T) {
+	usecontext(t, onetask())
+
+	req, err := http.NewRequest(GET, "/tasks/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", uberType)
+
+	w := httptest.NewRecorder()
+	router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("expected status %d, got %d", http.StatusNotAcceptable, w.Code)
+	}
+}
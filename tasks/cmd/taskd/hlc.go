@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxClockSkew is how far ahead of taskd's clock a client's timestamp may be. Without a bound
+// a client with a fast clock would win every merge.
+const maxClockSkew = time.Minute
+
+// serverNode names taskd in the timestamps of the changes it makes itself.
+const serverNode = "taskd"
+
+// timestamp is a reading of a hybrid logical clock: wall is physical time in milliseconds,
+// logical orders readings taken within the same millisecond, and node, the name of the clock,
+// breaks ties so that every timestamp is unique. Timestamps are sent as wall.logical.node,
+// e.g. 1700000000000.0.phone1.
+type timestamp struct {
+	wall    int64
+	logical int
+	node    string
+}
+
+func (t timestamp) String() string {
+	if t.isZero() {
+		return ""
+	}
+	return fmt.Sprintf("%d.%d.%s", t.wall, t.logical, t.node)
+}
+
+func (t timestamp) isZero() bool {
+	return t == timestamp{}
+}
+
+// before reports whether t is ordered before u.
+func (t timestamp) before(u timestamp) bool {
+	switch {
+	case t.wall != u.wall:
+		return t.wall < u.wall
+	case t.logical != u.logical:
+		return t.logical < u.logical
+	default:
+		return t.node < u.node
+	}
+}
+
+// parseTimestamp parses a timestamp in the form String returns.
+func parseTimestamp(s string) (timestamp, error) {
+	parts := strings.SplitN(s, ".", 3)
+	if len(parts) != 3 || len(parts[2]) == 0 {
+		return timestamp{}, fmt.Errorf("%q is not a timestamp", s)
+	}
+
+	wall, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || wall <= 0 {
+		return timestamp{}, fmt.Errorf("%q is not a timestamp", s)
+	}
+	logical, err := strconv.Atoi(parts[1])
+	if err != nil || logical < 0 {
+		return timestamp{}, fmt.Errorf("%q is not a timestamp", s)
+	}
+	return timestamp{wall: wall, logical: logical, node: parts[2]}, nil
+}
+
+// clock is a hybrid logical clock. Its readings follow physical time but never go backwards,
+// and a reading taken after observing another clock's timestamp is ordered after it.
+type clock struct {
+	mu   sync.Mutex
+	node string
+	last timestamp
+	now  func() time.Time
+}
+
+func newClock(node string) *clock {
+	return &clock{node: node, now: time.Now}
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// tick returns a new reading, ordered after every earlier one.
+func (c *clock) tick() timestamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if wall := millis(c.now()); wall > c.last.wall {
+		c.last = timestamp{wall: wall, node: c.node}
+	} else {
+		c.last = timestamp{wall: c.last.wall, logical: c.last.logical + 1, node: c.node}
+	}
+	return c.last
+}
+
+// observe advances the clock past a timestamp received from another clock. Timestamps more
+// than maxClockSkew ahead of physical time are refused.
+func (c *clock) observe(t timestamp) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if t.wall > millis(c.now().Add(maxClockSkew)) {
+		return fmt.Errorf("timestamp %s is too far in the future", t)
+	}
+	if c.last.before(t) {
+		c.last = timestamp{wall: t.wall, logical: t.logical, node: c.node}
+	}
+	return nil
+}
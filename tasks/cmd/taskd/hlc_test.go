@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := newClock("test")
+	c.now = func() time.Time { return now }
+
+	first := c.tick()
+	if first.String() != "1700000000000.0.test" {
+		t.Errorf("tick: expected 1700000000000.0.test, got %s", first)
+	}
+	if second := c.tick(); !first.before(second) || second.logical != 1 {
+		t.Errorf("tick in the same millisecond: expected %s after %s", second, first)
+	}
+
+	now = now.Add(-time.Second)
+	if third := c.tick(); third.wall != first.wall || third.logical != 2 {
+		t.Errorf("tick after the wall clock went back: expected 1700000000000.2.test, got %s", third)
+	}
+
+	remote := timestamp{wall: millis(now.Add(30 * time.Second)), logical: 4, node: "phone"}
+	if err := c.observe(remote); err != nil {
+		t.Fatalf("observe: unexpected error %v", err)
+	}
+	if next := c.tick(); !remote.before(next) {
+		t.Errorf("tick after observe: expected %s after %s", next, remote)
+	}
+
+	if err := c.observe(timestamp{wall: millis(now.Add(2 * maxClockSkew)), node: "phone"}); err == nil {
+		t.Errorf("observe: expected a timestamp beyond the skew bound to be refused")
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tt := []struct {
+		description string
+		s           string
+		ok          bool
+	}{
+		{"well formed", "1700000000000.3.phone1", true},
+		{"dotted node", "1700000000000.0.phone.local", true},
+		{"empty", "", false},
+		{"no node", "1700000000000.3.", false},
+		{"negative logical", "1700000000000.-1.phone", false},
+		{"not a number", "yesterday.0.phone", false},
+	}
+
+	for _, tst := range tt {
+		ts, err := parseTimestamp(tst.s)
+		if (err == nil) != tst.ok {
+			t.Errorf("%s: expected ok %t, got error %v", tst.description, tst.ok, err)
+			continue
+		}
+		if tst.ok && ts.String() != tst.s {
+			t.Errorf("%s: expected %s to round trip, got %s", tst.description, tst.s, ts)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/uber-apps/tasks/uber"
+)
+
+// htmlInput is a form field derived from an Uber model.
+type htmlInput struct {
+	Name   string
+	Value  string
+	Hidden bool
+}
+
+// htmlForm is the HTML rendering of an Uber transition that takes arguments or is unsafe.
+type htmlForm struct {
+	Method string
+	Action string
+	Submit string
+	Inputs []htmlInput
+}
+
+// htmlNode is the HTML view of an Uber data element.
+type htmlNode struct {
+	ID       string
+	Class    string
+	Rel      string
+	Label    string
+	Value    string
+	Href     string
+	Form     *htmlForm
+	Children []htmlNode
+}
+
+// htmlPage is the view rendered by pageTemplate.
+type htmlPage struct {
+	Title   string
+	Home    string
+	Data    []htmlNode
+	Problem *uber.Error
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+  <head>
+    <title>{{.Title}}</title>
+    <style>
+      ul {list-style:none; padding-left:1em}
+      form {display:inline}
+      span.text {padding-left:.5em}
+      .error {color:#a00}
+    </style>
+  </head>
+  <body>
+    <h1>{{.Title}}</h1>
+    {{- with .Problem}}
+    <div class="error">
+      <p>{{.Message}}</p>
+      <p>Error <code>{{.Code}}</code>{{if .Status}}, status {{.Status}}{{end}}.{{with .Help}} <a href="{{.}}" rel="help">What does this mean?</a>{{end}}</p>
+      {{- if .Fields}}
+      <ul>
+        {{- range .Fields}}
+        <li><code>{{.Field}}</code>: {{.Message}}</li>
+        {{- end}}
+      </ul>
+      {{- end}}
+      <p><a href="{{$.Home}}" rel="collection">Return to the task list</a></p>
+    </div>
+    {{- end}}
+    {{- if .Data}}
+    <ul>
+      {{- range .Data}}{{template "node" .}}{{end}}
+    </ul>
+    {{- end}}
+  </body>
+</html>
+{{define "node"}}
+      <li{{with .ID}} id="{{.}}"{{end}}{{with .Class}} class="{{.}}"{{end}}>
+        {{- if .Form}}
+        <form method="{{.Form.Method}}" action="{{.Form.Action}}">
+          {{- range .Form.Inputs}}
+          {{- if .Hidden}}
+          <input type="hidden" name="{{.Name}}" value="{{.Value}}">
+          {{- else}}
+          <label>{{.Name}} <input type="text" name="{{.Name}}" value="{{.Value}}"></label>
+          {{- end}}
+          {{- end}}
+          <input type="submit" value="{{.Form.Submit}}">
+        </form>
+        {{- else if .Href}}
+        <a href="{{.Href}}"{{with .Rel}} rel="{{.}}"{{end}}>{{.Label}}</a>
+        {{- else if .Value}}
+        <span class="{{.Label}}">{{.Value}}</span>
+        {{- end}}
+        {{- if .Children}}
+        <ul>
+          {{- range .Children}}{{template "node" .}}{{end}}
+        </ul>
+        {{- end}}
+      </li>
+{{- end}}
+`))
+
+// parseModel derives form inputs from an Uber model such as text={text} or ?text={text}.
+// Arguments whose value is a {placeholder} become text inputs, any others are sent back
+// unchanged in hidden inputs.
+func parseModel(model string) []htmlInput {
+	inputs := []htmlInput{}
+	for _, arg := range strings.Split(strings.TrimPrefix(model, "?"), "&") {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv[0]) == 0 {
+			continue
+		}
+
+		input := htmlInput{Name: kv[0]}
+		if len(kv) == 2 && !(strings.HasPrefix(kv[1], "{") && strings.HasSuffix(kv[1], "}")) {
+			input.Value, input.Hidden = kv[1], true
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs
+}
+
+// htmlNodes creates the HTML view of a list of Uber data elements.
+func htmlNodes(ds []uber.Data) []htmlNode {
+	nodes := []htmlNode{}
+	for _, d := range ds {
+		n := htmlNode{ID: d.ID, Class: d.Name, Rel: strings.Join(d.Rel, " "), Label: d.Label, Value: d.Value}
+
+		if len(n.Label) == 0 {
+			switch {
+			case len(d.Rel) > 0:
+				n.Label = d.Rel[0]
+			case len(d.Name) > 0:
+				n.Label = d.Name
+			default:
+				n.Label = d.ID
+			}
+		}
+
+		switch method := uber.Method(d.Action); {
+		case len(d.URL) == 0:
+		case d.Template:
+			if tpl, err := uber.ParseTemplate(d.URL); err == nil {
+				n.Form = &htmlForm{Method: method, Action: tpl.Expand(nil), Submit: n.Label, Inputs: []htmlInput{}}
+				for _, v := range tpl.Variables() {
+					n.Form.Inputs = append(n.Form.Inputs, htmlInput{Name: v})
+				}
+			}
+		case method == "GET" && len(d.Model) == 0:
+			n.Href = d.URL
+		default:
+			if method != "GET" {
+				method = "POST"
+			}
+			n.Form = &htmlForm{Method: method, Action: d.URL, Submit: n.Label, Inputs: parseModel(d.Model)}
+		}
+
+		n.Children = htmlNodes(d.Data)
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// renderHTML renders an Uber document as an HTML page. Links become anchors, transitions
+// that take arguments become forms and the error section becomes a readable error report.
+func renderHTML(ud *uber.Doc) ([]byte, error) {
+	page := htmlPage{Title: "Tasks UBER", Home: transitions["list"].href(), Data: htmlNodes(ud.Uber.Data), Problem: uber.ErrorFromDoc(ud)}
+
+	buf := bytes.NewBuffer([]byte{})
+	if err := pageTemplate.Execute(buf, page); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// prefersHTML reports whether the request would rather have HTML than Uber in response.
+func prefersHTML(req *http.Request) bool {
+	return negotiate(req.Header.Get("Accept"), []string{uberType, htmlType}) == htmlType
+}
+
+// writeDone responds to a successful unsafe request. Browsers submitting forms are redirected
+// back to the task list, every other client gets 204 No Content.
+func writeDone(w http.ResponseWriter, req *http.Request) {
+	if prefersHTML(req) {
+		http.Redirect(w, req, transitions["list"].href(), http.StatusSeeOther)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
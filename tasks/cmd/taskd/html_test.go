@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const browserAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+
+func TestParseModel(t *testing.T) {
+	tt := []struct {
+		model  string
+		inputs []htmlInput
+	}{
+		{"", []htmlInput{}},
+		{"text={text}", []htmlInput{{Name: "text"}}},
+		{"?text={text}", []htmlInput{{Name: "text"}}},
+		{"id=task1", []htmlInput{{Name: "id", Value: "task1", Hidden: true}}},
+		{"id=task1&text={text}", []htmlInput{{Name: "id", Value: "task1", Hidden: true}, {Name: "text"}}},
+	}
+
+	for _, tst := range tt {
+		if inputs := parseModel(tst.model); !reflect.DeepEqual(inputs, tst.inputs) {
+			t.Errorf("parseModel(%q): expected %+v, got %+v", tst.model, tst.inputs, inputs)
+		}
+	}
+}
+
+func TestHTML(t *testing.T) {
+	tt := []struct {
+		description string
+		hfn         ContextHandlerFunc
+		req         string
+		method      string
+		payload     string
+		rc          int
+		contains    []string
+	}{
+		{"task list", tasklist, "/tasks", GET, "", 200, []string{
+			`<a href="/tasks-alps.xml" rel="profile">profile</a>`,
+			`<form method="GET" action="/tasks/search">`,
+			`<label>text <input type="text" name="text" value=""></label>`,
+			`<form method="POST" action="/tasks">`,
+			`<input type="text" name="text" value="">`,
+			`<input type="hidden" name="id" value="task2">`,
+			`<span class="text">task three</span>`,
+		}},
+		{"error page", tasksearch, "/tasks/search", GET, "", 400, []string{
+			`<p>Missing text parameter</p>`,
+			`<a href="/errors#missing_parameter" rel="help">`,
+			`<li><code>text</code>: The text to search for is required</li>`,
+		}},
+		{"add redirects to list", taskadd, "/tasks", POST, "text=another task", 303, []string{}},
+	}
+
+	for _, tst := range tt {
+		req, err := http.NewRequest(tst.method, tst.req, strings.NewReader(tst.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", browserAccept)
+
+		w := httptest.NewRecorder()
+		tst.hfn(multipletasks(), w, req)
+
+		if w.Code != tst.rc {
+			t.Errorf("%s: Response Code mismatch: expected %d, got %d", tst.description, tst.rc, w.Code)
+			continue
+		}
+
+		for _, s := range tst.contains {
+			if !strings.Contains(w.Body.String(), s) {
+				t.Errorf("%s: expected body containing %s, got\n%s", tst.description, s, w.Body.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxIdempotencyKeyLength is the longest Idempotency-Key taskd accepts.
+const maxIdempotencyKeyLength = 255
+
+// idempotencyWindow is how long taskd remembers an Idempotency-Key and the response to the
+// request that carried it.
+var idempotencyWindow = 24 * time.Hour
+
+// idempotencySweep is how often expired keys are dropped.
+const idempotencySweep = time.Minute
+
+// maxIdempotencyKeys bounds how many keys a route remembers. When it is reached the outcome
+// closest to expiring is forgotten early to make room.
+var maxIdempotencyKeys = 10000
+
+// outcome is what taskd remembers about a request made with an Idempotency-Key. Until the
+// request has been handled done is false.
+type outcome struct {
+	digest  [sha256.Size]byte
+	expires time.Time
+	done    bool
+	code    int
+	header  http.Header
+	body    []byte
+}
+
+// idempotencyKeys remembers the outcomes of the requests made to one route. Swept is when
+// expired outcomes were last dropped.
+type idempotencyKeys struct {
+	mu       sync.Mutex
+	outcomes map[string]*outcome
+	now      func() time.Time
+	swept    time.Time
+}
+
+// idempotent makes h safe to retry. A request carrying an Idempotency-Key header is handled
+// once; repeats of it within idempotencyWindow get the original response replayed, marked
+// with an Idempotent-Replayed header. Reusing a key for a request with a different body is
+// refused, as is a repeat that arrives while the original is still being handled. Requests
+// without the header are passed to h unchanged.
+func idempotent(h http.Handler) http.Handler {
+	keys := &idempotencyKeys{outcomes: map[string]*outcome{}, now: time.Now}
+	return keys.handler(h)
+}
+
+func (keys *idempotencyKeys) handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get("Idempotency-Key")
+		if len(key) == 0 {
+			h.ServeHTTP(w, req)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(w, req, newError(errInvalidIdempotencyKey, "The Idempotency-Key header is too long"))
+			return
+		}
+
+		body, e := readBody(req)
+		if e != nil {
+			writeError(w, req, e)
+			return
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		digest := sha256.Sum256(append([]byte(req.Header.Get("Content-Type")+"\n"), body...))
+
+		o, seen := keys.begin(key, digest)
+		switch {
+		case seen && o.digest != digest:
+			writeError(w, req, newError(errIdempotencyKeyReused, "The Idempotency-Key was used for a different request"))
+			return
+		case seen && !o.done:
+			writeError(w, req, newError(errIdempotencyKeyInUse, "A request with this Idempotency-Key is still being handled"))
+			return
+		case seen:
+			for k, vs := range o.header {
+				w.Header()[k] = vs
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(o.code)
+			w.Write(o.body)
+			return
+		}
+
+		// If h panics the key is forgotten, so that the request can be retried rather than
+		// being refused as in use forever.
+		finished := false
+		defer func() {
+			if !finished {
+				keys.forget(key)
+			}
+		}()
+
+		cw := &captureWriter{header: http.Header{}, code: http.StatusOK}
+		h.ServeHTTP(cw, req)
+		keys.finish(key, cw)
+		finished = true
+
+		for k, vs := range cw.header {
+			w.Header()[k] = vs
+		}
+		w.WriteHeader(cw.code)
+		w.Write(cw.body.Bytes())
+	})
+}
+
+// begin looks up the outcome of an earlier request with key and returns a copy of it. If
+// there is none, or it has expired, it records that a request with key and the body digest
+// is being handled, making room for it if the route already remembers maxIdempotencyKeys
+// keys.
+func (keys *idempotencyKeys) begin(key string, digest [sha256.Size]byte) (outcome, bool) {
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+
+	if o, ok := keys.outcomes[key]; ok && !(o.done && keys.now().After(o.expires)) {
+		return *o, true
+	}
+	delete(keys.outcomes, key)
+
+	// Requests still being handled are never evicted; there are only as many as there are
+	// concurrent requests.
+	for len(keys.outcomes) >= maxIdempotencyKeys {
+		oldest := ""
+		for k, o := range keys.outcomes {
+			if o.done && (len(oldest) == 0 || o.expires.Before(keys.outcomes[oldest].expires)) {
+				oldest = k
+			}
+		}
+		if len(oldest) == 0 {
+			break
+		}
+		delete(keys.outcomes, oldest)
+	}
+
+	keys.outcomes[key] = &outcome{digest: digest}
+	return outcome{}, false
+}
+
+// forget drops the outcome of the request with key.
+func (keys *idempotencyKeys) forget(key string) {
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+
+	delete(keys.outcomes, key)
+}
+
+// finish records the response to the request with key. Server errors aren't remembered, so
+// that the request can be retried. Every idempotencySweep it also drops the outcomes that
+// have expired.
+func (keys *idempotencyKeys) finish(key string, cw *captureWriter) {
+	keys.mu.Lock()
+	defer keys.mu.Unlock()
+
+	now := keys.now()
+	if now.Sub(keys.swept) >= idempotencySweep {
+		for k, o := range keys.outcomes {
+			if o.done && now.After(o.expires) {
+				delete(keys.outcomes, k)
+			}
+		}
+		keys.swept = now
+	}
+
+	o, ok := keys.outcomes[key]
+	if !ok {
+		return
+	}
+	if cw.code >= http.StatusInternalServerError {
+		delete(keys.outcomes, key)
+		return
+	}
+
+	o.done, o.code, o.header, o.body = true, cw.code, cw.header, cw.body.Bytes()
+	o.expires = now.Add(idempotencyWindow)
+}
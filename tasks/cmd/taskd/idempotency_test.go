@@ -0,0 +1,236 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/uber-apps/tasks/uber"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	usecontext(t, notasks())
+	r := router()
+
+	post := func(key, payload string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(POST, "/tasks", strings.NewReader(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(key) > 0 {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	tt := []struct {
+		description string
+		key         string
+		payload     string
+		rc          int
+		replayed    bool
+		code        string
+		count       int
+	}{
+		{"first request", "k1", "text=task one", 204, false, "", 1},
+		{"retry", "k1", "text=task one", 204, true, "", 1},
+		{"another retry", "k1", "text=task one", 204, true, "", 1},
+		{"reused key", "k1", "text=task two", 422, false, errIdempotencyKeyReused, 1},
+		{"new key", "k2", "text=task one", 204, false, "", 2},
+		{"no key", "", "text=task one", 204, false, "", 3},
+		{"no key again", "", "text=task one", 204, false, "", 4},
+		{"failed request", "k3", "task=task three", 400, false, errInvalidBody, 4},
+		{"retried failure", "k3", "task=task three", 400, true, errInvalidBody, 4},
+		{"long key", strings.Repeat("k", maxIdempotencyKeyLength+1), "text=task one", 400, false, errInvalidIdempotencyKey, 4},
+	}
+
+	for _, tst := range tt {
+		w := post(tst.key, tst.payload)
+
+		if w.Code != tst.rc {
+			t.Errorf("%s: expected status %d, got %d", tst.description, tst.rc, w.Code)
+			continue
+		}
+		if replayed := w.Header().Get("Idempotent-Replayed") == "true"; replayed != tst.replayed {
+			t.Errorf("%s: expected replayed %v, got %v", tst.description, tst.replayed, replayed)
+		}
+		if n := taskctx.Value("tasks").(*taskstore).len(); n != tst.count {
+			t.Errorf("%s: expected %d tasks, got %d", tst.description, tst.count, n)
+		}
+		if len(tst.code) > 0 {
+			ud, err := uber.Parse(w.Body)
+			if e := uber.ErrorFromDoc(ud); err != nil || e == nil || e.Code != tst.code {
+				t.Errorf("%s: expected error %s, got %v", tst.description, tst.code, e)
+			}
+		}
+	}
+}
+
+func TestIdempotencyWindow(t *testing.T) {
+	clock := time.Unix(0, 0)
+	keys := &idempotencyKeys{outcomes: map[string]*outcome{}, now: func() time.Time { return clock }}
+
+	calls := 0
+	h := keys.handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	post := func(key string) {
+		req, _ := http.NewRequest(POST, "/tasks", strings.NewReader("text=task one"))
+		req.Header.Set("Idempotency-Key", key)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	post("k1")
+	clock = clock.Add(idempotencyWindow - time.Second)
+	post("k1")
+	if calls != 1 {
+		t.Errorf("expected a repeat within the window to be replayed, got %d calls", calls)
+	}
+
+	clock = clock.Add(2 * time.Second)
+	post("k1")
+	if calls != 2 {
+		t.Errorf("expected the key to be forgotten after the window, got %d calls", calls)
+	}
+
+	clock = clock.Add(idempotencyWindow + time.Second)
+	post("k2")
+	if _, ok := keys.outcomes["k1"]; ok || len(keys.outcomes) != 1 {
+		t.Errorf("expected expired keys to be dropped, got %d keys", len(keys.outcomes))
+	}
+}
+
+func TestIdempotencyKeyInUse(t *testing.T) {
+	keys := &idempotencyKeys{outcomes: map[string]*outcome{}, now: time.Now}
+
+	var inner *httptest.ResponseRecorder
+	var h http.Handler
+	h = keys.handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// A repeat arriving while the first request is being handled.
+		repeat, _ := http.NewRequest(POST, "/tasks", strings.NewReader("text=task one"))
+		repeat.Header.Set("Idempotency-Key", "k1")
+		inner = httptest.NewRecorder()
+		h.ServeHTTP(inner, repeat)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req, _ := http.NewRequest(POST, "/tasks", strings.NewReader("text=task one"))
+	req.Header.Set("Idempotency-Key", "k1")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if inner.Code != http.StatusConflict {
+		t.Errorf("expected a concurrent repeat to get 409, got %d", inner.Code)
+	}
+}
+
+func TestIdempotencyConcurrent(t *testing.T) {
+	keys := &idempotencyKeys{outcomes: map[string]*outcome{}, now: time.Now}
+
+	var calls int32
+	h := keys.handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(time.Millisecond)
+		w.Header().Set("Location", "/tasks/task1")
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	codes := make(chan int, 50)
+	var wg sync.WaitGroup
+	for i := 0; i < cap(codes); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(POST, "/tasks", strings.NewReader("text=task one"))
+			req.Header.Set("Idempotency-Key", "k1")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code == http.StatusCreated && w.Header().Get("Location") != "/tasks/task1" {
+				t.Errorf("expected the original Location to be replayed, got %q", w.Header().Get("Location"))
+			}
+			codes <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	if calls != 1 {
+		t.Errorf("expected the request to be handled once, got %d calls", calls)
+	}
+	for code := range codes {
+		if code != http.StatusCreated && code != http.StatusConflict {
+			t.Errorf("expected the original response or 409, got %d", code)
+		}
+	}
+}
+
+func TestIdempotencyKeyLimit(t *testing.T) {
+	defer func(max int) { maxIdempotencyKeys = max }(maxIdempotencyKeys)
+	maxIdempotencyKeys = 2
+
+	clock := time.Unix(0, 0)
+	keys := &idempotencyKeys{outcomes: map[string]*outcome{}, now: func() time.Time { return clock }}
+
+	calls := 0
+	h := keys.handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	post := func(key string) {
+		req, _ := http.NewRequest(POST, "/tasks", strings.NewReader("text=task one"))
+		req.Header.Set("Idempotency-Key", key)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		clock = clock.Add(time.Second)
+	}
+
+	for _, key := range []string{"k1", "k2", "k3", "k2"} {
+		post(key)
+	}
+	if len(keys.outcomes) != 2 {
+		t.Errorf("expected at most 2 keys to be remembered, got %d", len(keys.outcomes))
+	}
+	if calls != 3 {
+		t.Errorf("expected the repeat of a remembered key to be replayed, got %d calls", calls)
+	}
+
+	post("k1")
+	if calls != 4 {
+		t.Errorf("expected the oldest key to have been forgotten, got %d calls", calls)
+	}
+}
+
+func TestIdempotencyPanic(t *testing.T) {
+	keys := &idempotencyKeys{outcomes: map[string]*outcome{}, now: time.Now}
+
+	calls := 0
+	h := keys.handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	post := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(POST, "/tasks", strings.NewReader("text=task one"))
+		req.Header.Set("Idempotency-Key", "k1")
+		w := httptest.NewRecorder()
+		defer func() { recover() }()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	post()
+	if len(keys.outcomes) != 0 {
+		t.Errorf("expected the key of a panicking request to be forgotten, got %d keys", len(keys.outcomes))
+	}
+	if w := post(); w.Code != http.StatusNoContent || calls != 2 {
+		t.Errorf("expected the retry to be handled, got %d after %d calls", w.Code, calls)
+	}
+}
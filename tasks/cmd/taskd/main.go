@@ -2,15 +2,16 @@
 package main
 
 import (
-	"container/list"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
-	"regexp"
+	"path"
 	"strconv"
+	"strings"
+
+	"github.com/uber-apps/tasks/uber"
 
 	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/github.com/gorilla/handlers"
 	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/github.com/gorilla/mux"
@@ -45,242 +46,285 @@ func (ca ContextAdapter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	ca.handler.ServeHTTPWithContext(ca.ctx, w, req)
 }
 
-// udata represents the individual data elements of an Uber hypermedia document.
-type udata struct {
-	ID         string   `json:"id,omitempty"`
-	Name       string   `json:"name,omitempty"`
-	Rel        []string `json:"rel,omitempty"`
-	Label      string   `json:"label,omitempty"`
-	URL        string   `json:"url,omitempty"`
-	Template   bool     `json:"template,omitempty"`
-	Action     string   `json:"action,omitempty"`
-	Transclude bool     `json:"transclude,omitempty"`
-	Model      string   `json:"model,omitempty"`
-	Sending    string   `json:"sending,omitempty"`
-	Accepting  []string `json:"accepting,omitempty"`
-	Value      string   `json:"value,omitempty"`
-	Data       []udata  `json:"data,omitempty"`
-}
-
-// ubody is the body of an Uber hypermedia document.
-type ubody struct {
-	Version string  `json:"version"`
-	Data    []udata `json:"data,omitempty"`
-	Error   []udata `json:"error,omitempty"`
-}
-
-// udoc represents an Uber hypermedia document.
-type udoc struct {
-	Uber ubody `json:"uber"`
+// appendItem adds a task to the Uber hypermedia document.
+func appendItem(ud *uber.Doc, taskid, value string) {
+	tasks := ud.FindByID("tasks")
+	tasks.Data = append(tasks.Data, taskData(taskid, value, itemlinks).Build())
 }
 
-// appendItem adds a task to the Uber hypermedia document.
-func (ud *udoc) appendItem(taskid, value string) {
-	task := udata{ID: taskid,
-		Rel:  []string{"item"},
-		Name: "tasks",
-		Data: []udata{
-			udata{Rel: []string{"complete"}, URL: "/tasks/complete/", Model: fmt.Sprintf("id=%s", taskid), Action: "append"},
-			udata{Name: "text", Value: value}}}
-
-	ud.Uber.Data[1].Data = append(ud.Uber.Data[1].Data, task)
+// taskData creates the Uber representation of a task carrying the named transitions.
+func taskData(taskid, value string, links []string) *uber.DataBuilder {
+	task := uber.NewData().ID(taskid).Rel("item").Name("tasks")
+	for _, id := range links {
+		task.Append(transitions[id].link("id", taskid))
+	}
+	return task.Append(uber.NewData().Name("text").Value(value))
 }
 
 var (
 	taskctx = context.Background()
+
+	// routes is the router taskd serves requests with. Links are reversed from its routes.
+	routes *mux.Router
 )
 
+// init sets up an in-memory store, and the routes links are reversed from, for use before
+// main has read the configuration.
 func init() {
-	taskctx = context.WithValue(taskctx, "tasks", list.New())
-	taskctx = context.WithValue(taskctx, "logger", log.New(os.Stdout, "taskd: ", log.LstdFlags))
-	http.Handle("/", handlers.CompressHandler(handlers.LoggingHandler(os.Stdout, router())))
+	taskctx = newContext(newStore(), log.New(os.Stdout, "taskd: ", log.LstdFlags))
+	routes = router()
+}
+
+// newContext creates the context handlers are served with.
+func newContext(tasks *taskstore, logger *log.Logger) context.Context {
+	ctx := context.WithValue(context.Background(), "tasks", tasks)
+	ctx = context.WithValue(ctx, "webhooks", newWebhooks())
+	return context.WithValue(ctx, "logger", logger)
 }
 
 func main() {
-	http.ListenAndServe(":3006", nil)
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:], os.Stdout))
+	}
+
+	args, printing := os.Args[1:], false
+	if len(args) > 0 && args[0] == "config" {
+		if len(args) < 2 || args[1] != "print" {
+			fmt.Fprintln(os.Stderr, "usage: taskd config print [flags]")
+			os.Exit(2)
+		}
+		args, printing = args[2:], true
+	}
+
+	c, err := loadConfig(args, os.LookupEnv, os.Stderr)
+	switch {
+	case err == flag.ErrHelp:
+		os.Exit(0)
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "taskd: %v\n", err)
+		os.Exit(2)
+	case printing:
+		if err := c.print(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "taskd: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	log.Fatal(serve(c))
+}
+
+// serve runs taskd with the configuration c until its server fails.
+func serve(c config) error {
+	logger := log.New(os.Stdout, "taskd: ", log.LstdFlags)
+
+	tasks, err := c.openStore()
+	if err != nil {
+		return err
+	}
+	if c.store == "file" {
+		go tasks.persist(c.storePath, nil, logger)
+	}
+
+	c.apply()
+	taskctx = newContext(tasks, logger)
+	routes = router()
+	go taskctx.Value("webhooks").(*webhooks).dispatch(tasks.feed, nil)
+
+	srv := c.server(c.handler(routes), logger)
+	if len(c.tlsCert) > 0 {
+		return srv.ListenAndServeTLS(c.tlsCert, c.tlsKey)
+	}
+	return srv.ListenAndServe()
 }
 
 func router() *mux.Router {
 	r := mux.NewRouter()
-	r.Handle("/tasks", http.Handler(ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(tasklist)})).Methods("GET")
-	r.Handle("/tasks", http.Handler(ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(taskadd)})).Methods("POST")
-	r.Handle("/tasks/complete", http.Handler(ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(taskcomplete)})).Methods("POST")
-	r.Handle("/tasks/search", http.Handler(ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(tasksearch)})).Methods("GET")
+	r.Handle("/tasks", http.Handler(ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(tasklist)})).Methods("GET").Name("list")
+	r.Handle("/tasks/{id:task[1-9][0-9]*}", http.Handler(ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(taskitem)})).Methods("GET").Name("item")
+	r.Handle("/tasks", idempotent(sends(transitions["add"], ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(taskadd)}))).Methods("POST").Name("add")
+	r.Handle("/tasks/complete", sends(transitions["complete"], ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(taskcomplete)})).Methods("POST").Name("complete")
+	// Browsers can only submit forms with POST, so edit accepts it alongside PUT.
+	r.Handle("/tasks/edit", sends(transitions["edit"], ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(taskedit)})).Methods("PUT", "POST").Name("edit")
+	r.Handle("/tasks/move", sends(transitions["move"], ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(taskmove)})).Methods("PUT", "POST").Name("move")
+	r.Handle("/tasks/search", http.Handler(ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(tasksearch)})).Methods("GET").Name("search")
+	r.Handle("/tasks/metadata", http.Handler(ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(taskmetadata)})).Methods("GET").Name("metadata")
+	r.Handle("/tasks/sync", http.Handler(ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(tasksync)})).Methods("GET").Name("sync")
+	r.Handle("/tasks/merge", sends(transitions["merge"], ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(taskmerge)})).Methods("POST").Name("merge")
+	r.Handle("/tasks/conflicts", http.Handler(ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(taskconflicts)})).Methods("GET").Name("conflicts")
+	r.Handle("/tasks/conflicts/resolve", sends(transitions["resolve"], ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(taskresolve)})).Methods("POST").Name("resolve")
+	r.Handle("/tasks/events", http.Handler(ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(taskevents)})).Methods("GET").Name("events")
+	r.Handle("/webhooks", http.Handler(ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(webhooklist)})).Methods("GET").Name("webhooks")
+	r.Handle("/webhooks", sends(transitions["subscribe"], ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(webhooksubscribe)})).Methods("POST").Name("subscribe")
+	r.Handle("/webhooks/remove", sends(transitions["unsubscribe"], ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(webhookunsubscribe)})).Methods("POST").Name("unsubscribe")
+	r.Handle("/webhooks/deadletters", http.Handler(ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(webhookdeadletters)})).Methods("GET").Name("deadletters")
+	for _, a := range assets {
+		r.Handle(a.path, http.Handler(ContextAdapter{ctx: taskctx, handler: clientasset(a)})).Methods("GET", "HEAD")
+	}
+	r.Handle(errorsURL, http.Handler(ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(errordocs)})).Methods("GET")
+	r.Handle(profileURL, http.Handler(ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(alpsprofile)})).Methods("GET")
+	r.Handle(profileJSONURL, http.Handler(ContextAdapter{ctx: taskctx, handler: ContextHandlerFunc(alpsprofile)})).Methods("GET")
+	r.NotFoundHandler = notFound(r)
 	return r
 }
 
-// taskadd adds a task to the list.
+// compress compresses the responses of h, except those of the change feed, whose events
+// have to reach clients as they happen.
+func compress(h http.Handler) http.Handler {
+	ch := handlers.CompressHandler(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.TrimRight(req.URL.Path, "/") == transitions["events"].href() {
+			h.ServeHTTP(w, req)
+			return
+		}
+		ch.ServeHTTP(w, req)
+	})
+}
+
+// trimSlash removes the trailing slash from request paths so that /tasks/ and /tasks reach
+// the same route. Unlike the router's StrictSlash, which redirects, it works for POST and PUT
+// requests too since clients turn redirected POSTs into GETs.
+func trimSlash(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if p := req.URL.Path; len(p) > 1 && strings.HasSuffix(p, "/") {
+			req.URL.Path = strings.TrimRight(p, "/")
+			if len(req.URL.Path) == 0 {
+				req.URL.Path = "/"
+			}
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// taskadd adds a task to the list. It expects a body containing text={text} where {text}
+// is the text of the new task.
 func taskadd(ctx context.Context, w http.ResponseWriter, req *http.Request) {
-	body, err := ioutil.ReadAll(req.Body)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(mkError("ServerError", "reason", "Cannot read HTTP request body"))
+	cmd, e := decodeAdd(req)
+	if e != nil {
+		writeError(w, req, e)
 		return
 	}
 
-	re := regexp.MustCompile("text=(([[:word:]]|[[:space:]])*)")
-	sm := re.FindStringSubmatch(string(body))
-	if sm == nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(mkError("ClientError", "reason", "Unrecognized add task body"))
+	tasks := ctx.Value("tasks").(*taskstore)
+	if err := tasks.add(cmd.text, ifMatch(req)); err != nil {
+		writeError(w, req, storeError(err))
 		return
 	}
 
-	tasks := ctx.Value("tasks").(*list.List)
-	tasks.PushBack(sm[1])
-
-	w.WriteHeader(http.StatusNoContent)
+	writeDone(w, req)
 }
 
 // taskcomplete removes a task from the list. It expects a body containing id={task} where
 // {task} is the id of the task to be removed.
 func taskcomplete(ctx context.Context, w http.ResponseWriter, req *http.Request) {
-	body, err := ioutil.ReadAll(req.Body)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(mkError("ServerError", "reason", "Cannot read HTTP request body"))
+	cmd, e := decodeComplete(req)
+	if e != nil {
+		writeError(w, req, e)
 		return
 	}
 
-	re := regexp.MustCompile("id=[[:alpha:]]+([[:digit:]]+)")
-	sm := re.FindStringSubmatch(string(body))
-	if sm == nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(mkError("ClientError", "reason", "Unrecognized complete text body"))
+	tasks := ctx.Value("tasks").(*taskstore)
+	if err := tasks.complete(cmd.position, ifMatch(req)); err != nil {
+		writeError(w, req, storeError(err))
 		return
 	}
 
-	completed := false
-	taskid, err := strconv.Atoi(sm[1])
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(mkError("ServerError", "reason", "Cannot read HTTP request body"))
+	writeDone(w, req)
+}
+
+// taskedit replaces the text of a task. It expects a body containing id={task}&text={text}
+// where {task} is the id of the task to be changed and {text} its new text.
+func taskedit(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	cmd, e := decodeEdit(req)
+	if e != nil {
+		writeError(w, req, e)
 		return
 	}
 
-	tasks := ctx.Value("tasks").(*list.List)
-
-	if tasks.Len() < taskid {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write(mkError("ClientError", "reason", "No such task"))
+	tasks := ctx.Value("tasks").(*taskstore)
+	if err := tasks.edit(cmd.position, cmd.text, ifMatch(req)); err != nil {
+		writeError(w, req, storeError(err))
 		return
 	}
 
-	for t, i := tasks.Front(), 1; t != nil; t = t.Next() {
-		if i == taskid {
-			completed = true
-			tasks.Remove(t)
-		}
-		i++
+	writeDone(w, req)
+}
+
+// taskmove moves a task to another position in the list. It expects a body containing
+// id={task}&position={position} where {task} is the id of the task to be moved and
+// {position} the place in the list it moves to, counting from 1.
+func taskmove(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	cmd, e := decodeMove(req)
+	if e != nil {
+		writeError(w, req, e)
+		return
 	}
 
-	if !completed {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write(mkError("ClientError", "reason", "No such task"))
+	tasks := ctx.Value("tasks").(*taskstore)
+	if err := tasks.move(cmd.position, cmd.to, ifMatch(req)); err != nil {
+		writeError(w, req, storeError(err))
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	writeDone(w, req)
 }
 
 // tasklist responds with the list of tasks.
 func tasklist(ctx context.Context, w http.ResponseWriter, req *http.Request) {
-	tasks := ctx.Value("tasks").(*list.List)
+	tasks := ctx.Value("tasks").(*taskstore)
 
-	resp := mkEmptylist()
-	if resp == nil {
-		panic("can't generate base UBER document")
-	}
+	writeCollection(ctx, w, req, tasks.list(nil))
+}
 
-	for t, i := tasks.Front(), 0; t != nil; t = t.Next() {
-		resp.appendItem(fmt.Sprintf("task%d", i+1), t.Value.(string))
-		i++
+// taskitem responds with a single task, named by the last segment of the request path.
+func taskitem(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	tasks := ctx.Value("tasks").(*taskstore)
+
+	sm := taskidRE.FindStringSubmatch(path.Base(req.URL.Path))
+	if sm == nil {
+		writeError(w, req, newError(errTaskNotFound, "No such task"))
+		return
 	}
 
-	bs, err := json.Marshal(resp)
+	position, _ := strconv.Atoi(sm[1])
+	c, err := tasks.get(position)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(mkError("ServerError", "reason", "Cannot read HTTP request body"))
+		writeError(w, req, storeError(err))
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(bs)
+	writeCollection(ctx, w, req, c)
 }
 
-// tasksearch searches the task list. The search criteria is specified by a query parameter
-// of the form text={text} where {text} is matched against the task's value string.
+// tasksearch searches the task list. The search criteria is specified by the variables of
+// the search transition's URI template, i.e. text={text} where {text} is matched against
+// the task's value string.
 func tasksearch(ctx context.Context, w http.ResponseWriter, req *http.Request) {
-	tasks := ctx.Value("tasks").(*list.List)
+	tasks := ctx.Value("tasks").(*taskstore)
 
-	qt := req.URL.Query().Get("text")
-	if len(qt) <= 0 {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(mkError("ClientError", "reason", "Missing text parameter"))
+	values, ok := uber.MustParseTemplate(transitions["search"].template()).Match(req.URL.RequestURI())
+	if !ok {
+		writeError(w, req, newError(errTemplateMismatch, "Request does not match the search template"))
 		return
 	}
 
-	resp := mkEmptylist()
-	if resp == nil {
-		panic("can't generate base UBER document")
-	}
-
-	for t, i := tasks.Front(), 0; t != nil; t = t.Next() {
-		if qt == t.Value.(string) {
-			resp.appendItem(fmt.Sprintf("task%d", i+1), t.Value.(string))
-			i++
-		}
-	}
-
-	bs, err := json.Marshal(resp)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(mkError("ServerError", "reason", "Cannot read HTTP request body"))
+	qt := values["text"]
+	if len(qt) <= 0 {
+		writeError(w, req, newError(errMissingParameter, "Missing text parameter",
+			uber.FieldError{Field: "text", Code: fieldRequired, Message: "The text to search for is required"}))
+		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(bs)
+	writeCollection(ctx, w, req, tasks.list(func(text string) bool { return text == qt }))
 }
 
 // mkEmptylist creates an Uber hypermedia document that represents an empty task list.
-func mkEmptylist() *udoc {
-	links := udata{
-		ID: "links",
-		Data: []udata{
-			udata{ID: "alps",
-				Rel:    []string{"profile"},
-				URL:    "/tasks-alps.xml",
-				Action: "read",
-				Data:   []udata{}},
-			udata{ID: "list",
-				Name:   "links",
-				Rel:    []string{"collection"},
-				URL:    "/tasks/",
-				Action: "read",
-				Data:   []udata{}},
-			udata{ID: "search",
-				Name:   "links",
-				Rel:    []string{"search"},
-				URL:    "/tasks/search",
-				Action: "read",
-				Model:  "?text={text}",
-				Data:   []udata{}},
-			udata{ID: "add",
-				Name:   "links",
-				Rel:    []string{"add"},
-				URL:    "/tasks/",
-				Action: "append",
-				Model:  "text={text}",
-				Data:   []udata{}}}}
-
-	return &udoc{ubody{Version: "1.0", Data: []udata{links, udata{ID: "tasks", Data: []udata{}}}, Error: []udata{}}}
-}
-
-// mkError creates an Uber hypermedia document that represents an error.
-func mkError(name, rel, value string) []byte {
-	bs, err := json.Marshal(udoc{ubody{Version: "1.0", Error: []udata{udata{Name: name, Rel: []string{rel}, Value: value}}}})
-	if err != nil {
-		panic(err)
+func mkEmptylist() *uber.Doc {
+	links := uber.NewData().ID("links").
+		Append(uber.NewData().ID("alps").Rel("profile").URL(profileURL).Action(uber.ActionRead))
+	for _, id := range linkorder {
+		links.Append(transitions[id].link())
 	}
-	return bs
+
+	return uber.NewDoc().Data(links, uber.NewData().ID("tasks")).Build()
 }
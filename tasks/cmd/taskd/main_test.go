@@ -2,7 +2,6 @@ package main
 
 import (
 	"bytes"
-	"container/list"
 	"encoding/json"
 	"log"
 	"net/http"
@@ -11,6 +10,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/uber-apps/tasks/client"
 	"github.com/uber-apps/tasks/cmd/taskd/data"
 
 	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/golang.org/x/net/context"
@@ -19,6 +19,7 @@ import (
 const (
 	GET  = "GET"
 	POST = "POST"
+	PUT  = "PUT"
 )
 
 type tasktest struct {
@@ -38,15 +39,130 @@ var tt = []tasktest{
 	{"multiple tasks", tasklist, "/tasks", GET, "", multipletasks(), 200, data.Multipletasks},
 	{"add task to empty list", taskadd, "/tasks", POST, "text=another task", notasks(), 204, ""},
 	{"add task to existing tasks", taskadd, "/tasks", POST, "text=another task", multipletasks(), 204, ""},
+	{"add task with encoded text", taskadd, "/tasks", POST, "text=buy+eggs+%26+milk", notasks(), 204, ""},
 	{"bad add request", taskadd, "/tasks", POST, "task=another task", multipletasks(), 400, ""},
-	{"search empty list", tasksearch, "/tasks?text=task one", GET, "", notasks(), 200, data.Emptylist},
-	{"search for existing task", tasksearch, "/tasks?text=task two", GET, "", multipletasks(), 200, data.Tasktwo},
-	{"search for missing task", tasksearch, "/tasks?text=task three", GET, "", onetask(), 200, data.Emptylist},
-	{"bad search request", tasksearch, "/tasks?task=another task", GET, "", multipletasks(), 400, ""},
+	{"search empty list", tasksearch, "/tasks/search?text=task one", GET, "", notasks(), 200, data.Emptylist},
+	{"search for existing task", tasksearch, "/tasks/search?text=task two", GET, "", multipletasks(), 200, data.Tasktwo},
+	{"search for missing task", tasksearch, "/tasks/search?text=task three", GET, "", onetask(), 200, data.Emptylist},
+	{"bad search request", tasksearch, "/tasks/search?task=another task", GET, "", multipletasks(), 400, ""},
+	{"search outside template", tasksearch, "/tasks?text=task two", GET, "", multipletasks(), 404, ""},
 	{"complete existing task", taskcomplete, "/tasks/complete", POST, "id=task2", multipletasks(), 204, ""},
 	{"complete unknown task", taskcomplete, "/tasks/complete", POST, "id=task3", onetask(), 404, ""},
 	{"complete on empty list", taskcomplete, "/tasks/complete", POST, "id=task1", notasks(), 404, ""},
 	{"bad complete request", taskcomplete, "/tasks/complete", POST, "task=task4", multipletasks(), 400, ""},
+	{"edit existing task", taskedit, "/tasks/edit", PUT, "id=task2&text=task deux", multipletasks(), 204, ""},
+	{"edit unknown task", taskedit, "/tasks/edit", PUT, "id=task3&text=task trois", onetask(), 404, ""},
+	{"edit without text", taskedit, "/tasks/edit", PUT, "id=task1", onetask(), 400, ""},
+	{"bad edit request", taskedit, "/tasks/edit", PUT, "task=task1&text=task un", onetask(), 400, ""},
+	{"move existing task", taskmove, "/tasks/move", PUT, "id=task3&position=1", multipletasks(), 204, ""},
+	{"move unknown task", taskmove, "/tasks/move", PUT, "id=task3&position=1", onetask(), 404, ""},
+	{"move past the end", taskmove, "/tasks/move", PUT, "id=task1&position=4", multipletasks(), 400, ""},
+	{"move without position", taskmove, "/tasks/move", PUT, "id=task1", multipletasks(), 400, ""},
+}
+
+func TestEdit(t *testing.T) {
+	ctx := multipletasks()
+
+	req, err := http.NewRequest(PUT, "/tasks/edit", strings.NewReader("id=task2&text=task deux"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskedit(ctx, httptest.NewRecorder(), req)
+
+	texts := []string{}
+	for _, t := range ctx.Value("tasks").(*taskstore).snapshot(nil).tasks {
+		texts = append(texts, t.text)
+	}
+	if strings.Join(texts, ",") != "task one,task deux,task three" {
+		t.Errorf("expected the second task to be edited, got %v", texts)
+	}
+}
+
+// TestEditLink edits a task the way taskctl's edit command does, by following the edit link
+// each task in the list carries, which is why taskd has an edit transition.
+func TestEditLink(t *testing.T) {
+	ctx := multipletasks()
+	usecontext(t, ctx)
+	srv := httptest.NewServer(router())
+	defer srv.Close()
+
+	c, err := client.New(srv.URL + "/tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := c.Entry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, err := entry.Within("task2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edit, err := item.Transition("edit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edit.Method() != PUT || !strings.Contains(edit.Data.Model, "{text}") {
+		t.Errorf("expected edit to PUT the text, got %s with %q", edit.Method(), edit.Data.Model)
+	}
+	if _, err := edit.Do(map[string]string{"text": "task deux"}); err != nil {
+		t.Fatalf("following the edit link: %v", err)
+	}
+
+	texts := []string{}
+	for _, t := range ctx.Value("tasks").(*taskstore).snapshot(nil).tasks {
+		texts = append(texts, t.text)
+	}
+	if strings.Join(texts, ",") != "task one,task deux,task three" {
+		t.Errorf("expected the second task to be edited, got %v", texts)
+	}
+}
+
+func TestMove(t *testing.T) {
+	tt := []struct {
+		payload string
+		texts   string
+		from    string
+		id      string
+	}{
+		{"id=task1&position=3", "task two,task three,task one", "task1", "task3"},
+		{"id=task3&position=2", "task two,task one,task three", "task3", "task2"},
+		{"id=task2&position=2", "task two,task one,task three", "", ""},
+	}
+
+	ctx := multipletasks()
+	store := ctx.Value("tasks").(*taskstore)
+	for i, tst := range tt {
+		_, ch := store.feed.watch(0, false)
+
+		req, err := http.NewRequest(PUT, "/tasks/move", strings.NewReader(tst.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", formType)
+		taskmove(ctx, httptest.NewRecorder(), req)
+		store.feed.unwatch(ch)
+
+		texts := []string{}
+		for _, t := range store.snapshot(nil).tasks {
+			texts = append(texts, t.text)
+		}
+		if strings.Join(texts, ",") != tst.texts {
+			t.Errorf("%d: expected %s after moving %s, got %v", i, tst.texts, tst.payload, texts)
+		}
+
+		var events []event
+		for e := range ch {
+			events = append(events, e)
+		}
+		switch {
+		case len(tst.from) == 0 && len(events) > 0:
+			t.Errorf("%d: expected no event for a move in place, got %+v", i, events)
+		case len(tst.from) > 0 && (len(events) != 1 || events[0].kind != "move" || events[0].from != tst.from || events[0].task.id != tst.id):
+			t.Errorf("%d: expected a move from %s to %s, got %+v", i, tst.from, tst.id, events)
+		}
+	}
 }
 
 func TestTasks(t *testing.T) {
@@ -108,14 +224,13 @@ func equaljson(p, q []byte) bool {
 }
 
 func notasks() context.Context {
-	ctx := context.WithValue(context.Background(), "tasks", list.New())
+	ctx := context.WithValue(context.Background(), "tasks", newStore())
 	ctx = context.WithValue(ctx, "logger", log.New(os.Stdout, "testing: ", log.LstdFlags))
 	return ctx
 }
 
 func onetask() context.Context {
-	l := list.New()
-	l.PushBack("task one")
+	l := newStore("task one")
 
 	ctx := context.WithValue(context.Background(), "tasks", l)
 	ctx = context.WithValue(ctx, "logger", log.New(os.Stdout, "testing: ", log.LstdFlags))
@@ -123,12 +238,16 @@ func onetask() context.Context {
 }
 
 func multipletasks() context.Context {
-	l := list.New()
-	l.PushBack("task one")
-	l.PushBack("task two")
-	l.PushBack("task three")
+	l := newStore("task one", "task two", "task three")
 
 	ctx := context.WithValue(context.Background(), "tasks", l)
 	ctx = context.WithValue(ctx, "logger", log.New(os.Stdout, "testing: ", log.LstdFlags))
 	return ctx
 }
+
+// usecontext makes ctx the context the router's handlers run in until the test ends.
+func usecontext(t *testing.T, ctx context.Context) {
+	saved := taskctx
+	taskctx = ctx
+	t.Cleanup(func() { taskctx = saved })
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/golang.org/x/net/context"
+	"github.com/uber-apps/tasks/uber"
+)
+
+// maxMergeChanges is the most changes taskd merges in one request.
+const maxMergeChanges = 1000
+
+var (
+	uidRE = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	tagRE = regexp.MustCompile(`^[^\s]{1,50}$`)
+)
+
+// fieldChange is a change made to one field of a task, typically by a client while offline.
+// Field is text, tags or completed. Changes to tags add or remove, according to op, the tag
+// in value. Clock is when the change was made and base, if the client knows it, the
+// timestamp of the text the client changed.
+type fieldChange struct {
+	uid   string
+	field string
+	op    string
+	value string
+	clock timestamp
+	base  timestamp
+}
+
+// maxOpenConflicts is how many conflicts may be left unresolved. Merges are refused while
+// that many are open, and so are merges that would open more, so that clients that never
+// resolve them can't grow the list forever.
+var maxOpenConflicts = 1000
+
+// maxTombstones is how many completed tasks the store remembers, so that changes made to
+// them elsewhere are dropped rather than bringing them back. The oldest are forgotten first.
+var maxTombstones = 10000
+
+// conflict records a change that lost a merge in a way that needs a person to look at it:
+// an edit of a task's text made without seeing another edit, or a change made to a task that
+// was completed without it being seen. Kept is what the field holds after the merge and lost
+// what it would have held had the other change won.
+type conflict struct {
+	id        string
+	uid       string
+	field     string
+	kept      string
+	keptClock timestamp
+	lost      string
+	lostClock timestamp
+}
+
+// mergeResult reports what a merge did: the tasks it created or changed, as they now are,
+// and the conflicts it found.
+type mergeResult struct {
+	tasks     []task
+	completed int
+	conflicts []conflict
+}
+
+// merge applies changes made elsewhere to the store, field by field. The text of a task is
+// the one with the latest timestamp and a tag is present if its latest add is later than its
+// latest remove, so merging the same changes in any order, or more than once, has the same
+// result. Tasks seen for the first time are appended in the order of their earliest change.
+// Changes to completed tasks are dropped, and changes that leave a task as it was don't
+// change the store. Nothing is merged if that would leave more than maxOpenConflicts
+// conflicts open.
+func (s *taskstore) merge(changes []fieldChange) (mergeResult, error) {
+	for _, c := range changes {
+		if err := s.clock.observe(c.clock); err != nil {
+			return mergeResult{}, err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.conflicts) >= maxOpenConflicts {
+		return mergeResult{}, errConflictsFull
+	}
+
+	changes = append([]fieldChange{}, changes...)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].clock.before(changes[j].clock) })
+
+	// Tombstones and conflicts are collected as the changes are merged and only recorded once
+	// the merge is known to be allowed.
+	tombstones := map[string]timestamp{}
+	completed := func(uid string) (timestamp, bool) {
+		if done, ok := tombstones[uid]; ok {
+			return done, true
+		}
+		done, ok := s.completed[uid]
+		return done, ok
+	}
+	conflicts := []conflict{}
+	lose := func(uid, field, kept string, keptClock timestamp, lost string, lostClock timestamp) {
+		conflicts = append(conflicts, conflict{uid: uid, field: field, kept: kept, keptClock: keptClock, lost: lost, lostClock: lostClock})
+	}
+
+	// A task is created if it is new and has a text. One completed before it reached the
+	// store only leaves a tombstone.
+	created := map[string]timestamp{}
+	for _, c := range changes {
+		if _, done := completed(c.uid); done || s.position(c.uid) > 0 {
+			continue
+		}
+		if c.field == "completed" {
+			tombstones[c.uid] = c.clock
+			delete(created, c.uid)
+			continue
+		}
+		if _, seen := created[c.uid]; !seen && c.field == "text" {
+			created[c.uid] = c.clock
+		}
+	}
+
+	fresh := []string{}
+	for uid := range created {
+		fresh = append(fresh, uid)
+	}
+	sort.Slice(fresh, func(i, j int) bool {
+		ci, cj := created[fresh[i]], created[fresh[j]]
+		return ci.before(cj) || (ci == cj && fresh[i] < fresh[j])
+	})
+
+	// Tasks in the list are copied before they are changed, lists being sent may hold them.
+	tasks := map[string]*storedTask{}
+	for _, uid := range fresh {
+		tasks[uid] = &storedTask{uid: uid, tags: map[string]tagClocks{}}
+	}
+	positions := map[string]int{}
+	for i, t := range s.tasks {
+		positions[t.uid] = i + 1
+	}
+
+	result := mergeResult{}
+	touched, removed := map[string]bool{}, map[string]bool{}
+	for _, c := range changes {
+		t, ok := tasks[c.uid]
+		if position := positions[c.uid]; !ok && position > 0 {
+			t, ok = s.tasks[position-1].copy(), true
+			tasks[c.uid] = t
+		}
+		if !ok || removed[c.uid] {
+			if done, ok := completed(c.uid); ok && done.before(c.clock) && c.field != "completed" {
+				lose(c.uid, c.field, "", done, c.value, c.clock)
+			}
+			continue
+		}
+
+		switch c.field {
+		case "text":
+			concurrent := !c.base.isZero() && c.base != t.textClock
+			if t.textClock.before(c.clock) {
+				if concurrent && t.text != c.value {
+					lose(c.uid, c.field, c.value, c.clock, t.text, t.textClock)
+				}
+				t.text, t.textClock = c.value, c.clock
+				touched[c.uid] = true
+			} else if concurrent && t.text != c.value {
+				lose(c.uid, c.field, t.text, t.textClock, c.value, c.clock)
+			}
+
+		case "tags":
+			tc := t.tags[c.value]
+			if c.op == "add" && tc.added.before(c.clock) {
+				tc.added = c.clock
+				touched[c.uid] = true
+			}
+			if c.op == "remove" && tc.removed.before(c.clock) {
+				tc.removed = c.clock
+				touched[c.uid] = true
+			}
+			t.tags[c.value] = tc
+
+		case "completed":
+			if c.clock.before(t.textClock) {
+				lose(c.uid, c.field, "", c.clock, t.text, t.textClock)
+			}
+			tombstones[c.uid] = c.clock
+			removed[c.uid] = true
+		}
+	}
+
+	if len(conflicts) > 0 && len(s.conflicts)+len(conflicts) > maxOpenConflicts {
+		return mergeResult{}, errConflictsFull
+	}
+	for _, c := range conflicts {
+		result.conflicts = append(result.conflicts, s.conflict(c))
+	}
+	buried := []string{}
+	for uid := range tombstones {
+		buried = append(buried, uid)
+	}
+	sort.Slice(buried, func(i, j int) bool { return tombstones[buried[i]].before(tombstones[buried[j]]) })
+	for _, uid := range buried {
+		s.tombstone(uid, tombstones[uid])
+	}
+
+	// Edits are published before additions and completions last, from the end of the list,
+	// so that replaying the change log by position gives the same list.
+	for position := 1; position <= len(s.tasks); position++ {
+		if uid := s.tasks[position-1].uid; touched[uid] && !removed[uid] {
+			s.replace(position, tasks[uid])
+			result.tasks = append(result.tasks, s.view(position))
+		}
+	}
+	for _, uid := range fresh {
+		s.appendTask(*tasks[uid])
+		result.tasks = append(result.tasks, s.view(len(s.tasks)))
+	}
+	for position := len(s.tasks); position > 0; position-- {
+		if removed[s.tasks[position-1].uid] {
+			s.remove(position)
+			result.completed++
+		}
+	}
+
+	return result, nil
+}
+
+// tombstone remembers that the task uid was completed at done, forgetting the oldest
+// completions beyond maxTombstones. The caller must hold the store's lock.
+func (s *taskstore) tombstone(uid string, done timestamp) {
+	if _, ok := s.completed[uid]; !ok {
+		s.buried = append(s.buried, uid)
+	}
+	s.completed[uid] = done
+	for len(s.buried) > maxTombstones {
+		delete(s.completed, s.buried[0])
+		s.buried = s.buried[1:]
+	}
+}
+
+// conflict records c, giving it an id, and returns it. The caller must hold the store's lock.
+func (s *taskstore) conflict(c conflict) conflict {
+	s.conflictn++
+	c.id = fmt.Sprintf("conflict%d", s.conflictn)
+	s.conflicts = append(s.conflicts, c)
+	return c
+}
+
+// openConflicts returns the conflicts nobody has resolved yet, oldest first.
+func (s *taskstore) openConflicts() []conflict {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]conflict{}, s.conflicts...)
+}
+
+// resolve settles the conflict with id. If text is not empty it becomes the text of the task,
+// which is added to the list again if it was completed.
+func (s *taskstore) resolve(id, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, c := range s.conflicts {
+		if c.id != id {
+			continue
+		}
+
+		s.conflicts = append(s.conflicts[:i], s.conflicts[i+1:]...)
+		if len(text) == 0 {
+			return nil
+		}
+
+		if position := s.position(c.uid); position > 0 {
+			t := s.tasks[position-1].copy()
+			t.text, t.textClock = text, s.clock.tick()
+			s.replace(position, t)
+		} else {
+			s.appendTask(storedTask{uid: randomHex(16), text: text, textClock: s.clock.tick(), tags: map[string]tagClocks{}})
+		}
+		return nil
+	}
+	return errNoSuchConflict
+}
+
+// decodeMerge decodes and validates the body of a merge request, a JSON object whose changes
+// property lists the changes, e.g.
+//
+//	{"changes": [{"task": "7f3c", "field": "text", "value": "buy milk", "clock": "1700000000000.0.phone1"}]}
+func decodeMerge(req *http.Request) ([]fieldChange, *uber.Error) {
+	body, e := readBody(req)
+	if e != nil {
+		return nil, e
+	}
+
+	var doc struct {
+		Changes []struct {
+			Task  string `json:"task"`
+			Field string `json:"field"`
+			Op    string `json:"op"`
+			Value string `json:"value"`
+			Clock string `json:"clock"`
+			Base  string `json:"base"`
+		} `json:"changes"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, newError(errInvalidBody, "Malformed JSON body, expected an object with a list of changes")
+	}
+
+	const message = "Invalid merge body"
+	if len(doc.Changes) > maxMergeChanges {
+		return nil, newError(errInvalidBody, message,
+			uber.FieldError{Field: "changes", Code: fieldInvalid, Message: fmt.Sprintf("At most %d changes can be merged at once", maxMergeChanges)})
+	}
+
+	changes := []fieldChange{}
+	for i, c := range doc.Changes {
+		field := func(name string) string { return fmt.Sprintf("changes[%d].%s", i, name) }
+		fc := fieldChange{uid: c.Task, field: c.Field, op: c.Op, value: c.Value}
+		var err error
+
+		if !uidRE.MatchString(c.Task) {
+			return nil, newError(errInvalidBody, message,
+				uber.FieldError{Field: field("task"), Code: fieldInvalid, Message: fmt.Sprintf("%q is not a task uid", c.Task)})
+		}
+
+		switch c.Field {
+		case "text":
+			text, e := decodeText(map[string]string{"text": c.Value}, message)
+			if e != nil {
+				e.Fields[0].Field = field("value")
+				return nil, e
+			}
+			fc.value = text
+		case "tags":
+			if c.Op != "add" && c.Op != "remove" {
+				return nil, newError(errInvalidBody, message,
+					uber.FieldError{Field: field("op"), Code: fieldInvalid, Message: "A change to tags must add or remove"})
+			}
+			if !tagRE.MatchString(c.Value) {
+				return nil, newError(errInvalidBody, message,
+					uber.FieldError{Field: field("value"), Code: fieldInvalid, Message: fmt.Sprintf("%q is not a tag", c.Value)})
+			}
+		case "completed":
+		default:
+			return nil, newError(errInvalidBody, message,
+				uber.FieldError{Field: field("field"), Code: fieldInvalid, Message: "The field must be text, tags or completed"})
+		}
+
+		if fc.clock, err = parseTimestamp(c.Clock); err != nil {
+			return nil, newError(errInvalidBody, message,
+				uber.FieldError{Field: field("clock"), Code: fieldInvalid, Message: err.Error()})
+		}
+		if len(c.Base) > 0 {
+			if fc.base, err = parseTimestamp(c.Base); err != nil {
+				return nil, newError(errInvalidBody, message,
+					uber.FieldError{Field: field("base"), Code: fieldInvalid, Message: err.Error()})
+			}
+		}
+
+		changes = append(changes, fc)
+	}
+
+	return changes, nil
+}
+
+// taskmerge merges changes made offline into the task list and responds with the tasks it
+// changed and the conflicts it found.
+func taskmerge(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	changes, e := decodeMerge(req)
+	if e != nil {
+		writeError(w, req, e)
+		return
+	}
+
+	tasks := ctx.Value("tasks").(*taskstore)
+	result, err := tasks.merge(changes)
+	if err == errConflictsFull {
+		writeError(w, req, storeError(err))
+		return
+	}
+	if err != nil {
+		writeError(w, req, newError(errInvalidBody, "Invalid merge body",
+			uber.FieldError{Field: "changes", Code: fieldInvalid, Message: err.Error()}))
+		return
+	}
+
+	merged := uber.NewData().ID("tasks")
+	for _, t := range result.tasks {
+		merged.Append(taskData(t.id, t.text, itemlinks).Append(syncFields(t)...))
+	}
+
+	writeDoc(w, req, http.StatusOK, uber.NewDoc().Data(mergeLinks(), merged, conflictsData(result.conflicts)).Build())
+}
+
+// taskconflicts responds with the conflicts merges found that nobody has resolved yet.
+func taskconflicts(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	tasks := ctx.Value("tasks").(*taskstore)
+
+	writeDoc(w, req, http.StatusOK, uber.NewDoc().Data(mergeLinks(), conflictsData(tasks.openConflicts())).Build())
+}
+
+// taskresolve settles a conflict. It expects a body containing id={id}&text={text} where {id}
+// is the id of the conflict and {text}, if given, the text the task should have.
+func taskresolve(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	args, e := decodeArgs(req, "id", "text")
+	if e != nil {
+		writeError(w, req, e)
+		return
+	}
+
+	id := strings.TrimSpace(args["id"])
+	if len(id) == 0 {
+		writeError(w, req, newError(errInvalidBody, "Invalid resolve body",
+			uber.FieldError{Field: "id", Code: fieldRequired, Message: "The id of the conflict is required"}))
+		return
+	}
+
+	text := ""
+	if len(strings.TrimSpace(args["text"])) > 0 {
+		if text, e = decodeText(args, "Invalid resolve body"); e != nil {
+			writeError(w, req, e)
+			return
+		}
+	}
+
+	tasks := ctx.Value("tasks").(*taskstore)
+	if err := tasks.resolve(id, text); err != nil {
+		writeError(w, req, newError(errConflictNotFound, "No such conflict"))
+		return
+	}
+
+	writeDoc(w, req, http.StatusOK, uber.NewDoc().Data(mergeLinks(), conflictsData(tasks.openConflicts())).Build())
+}
+
+// mergeLinks creates the links block of the merge and conflict resources.
+func mergeLinks() *uber.DataBuilder {
+	links := uber.NewData().ID("links").Append(transitions["list"].link())
+	for _, id := range mergelinks {
+		links.Append(transitions[id].link())
+	}
+	return links
+}
+
+// conflictsData creates the Uber representation of conflicts, in a container named unresolved
+// so as not to clash with the conflicts link. Each links to the transition that resolves it.
+func conflictsData(conflicts []conflict) *uber.DataBuilder {
+	d := uber.NewData().ID("unresolved")
+	for _, c := range conflicts {
+		item := uber.NewData().ID(c.id).Name("conflicts")
+		for _, id := range conflictlinks {
+			item.Append(transitions[id].link("id", c.id))
+		}
+		d.Append(item.Append(
+			uber.NewData().Name("uid").Value(c.uid),
+			uber.NewData().Name("field").Value(c.field),
+			uber.NewData().Name("kept").Value(c.kept),
+			uber.NewData().Name("keptclock").Value(c.keptClock.String()),
+			uber.NewData().Name("lost").Value(c.lost),
+			uber.NewData().Name("lostclock").Value(c.lostClock.String())))
+	}
+	return d
+}
+
+// syncFields creates the elements, shown only to syncing clients, that carry what they need
+// to merge changes to t: its uid, the timestamp of its text and its tags.
+func syncFields(t task) []*uber.DataBuilder {
+	ds := []*uber.DataBuilder{
+		uber.NewData().Name("uid").Value(t.uid),
+		uber.NewData().Name("clock").Value(t.clock),
+	}
+	for _, tag := range t.tags {
+		ds = append(ds, uber.NewData().Name("tag").Value(tag))
+	}
+	return ds
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/uber-apps/tasks/uber"
+)
+
+// ts returns a timestamp from a client's clock, wall milliseconds after the epoch.
+func ts(wall int64, node string) timestamp {
+	return timestamp{wall: wall, node: node}
+}
+
+// describe summarises the tasks in a store as uid:text[tags] in list order.
+func describe(s *taskstore) string {
+	tasks := []string{}
+	for _, t := range s.snapshot(nil).tasks {
+		tasks = append(tasks, fmt.Sprintf("%s:%s%v", t.uid, t.text, t.tags))
+	}
+	return strings.Join(tasks, ",")
+}
+
+func TestMergeOrder(t *testing.T) {
+	changes := []fieldChange{
+		{uid: "a", field: "text", value: "buy milk", clock: ts(1000, "phone")},
+		{uid: "b", field: "text", value: "call mum", clock: ts(2000, "laptop")},
+		{uid: "a", field: "tags", op: "add", value: "shopping", clock: ts(3000, "phone")},
+		{uid: "a", field: "text", value: "buy oat milk", clock: ts(4000, "laptop")},
+		{uid: "a", field: "tags", op: "add", value: "errand", clock: ts(4000, "phone")},
+		{uid: "a", field: "tags", op: "remove", value: "shopping", clock: ts(5000, "laptop")},
+		{uid: "c", field: "text", value: "water plants", clock: ts(2500, "phone")},
+		{uid: "c", field: "completed", clock: ts(6000, "laptop")},
+	}
+	reversed := []fieldChange{}
+	for i := len(changes) - 1; i >= 0; i-- {
+		reversed = append(reversed, changes[i])
+	}
+
+	tt := []struct {
+		description string
+		batches     [][]fieldChange
+	}{
+		{"in order", [][]fieldChange{changes}},
+		{"reversed", [][]fieldChange{reversed}},
+		{"one at a time", func() [][]fieldChange {
+			batches := [][]fieldChange{}
+			for _, c := range changes {
+				batches = append(batches, []fieldChange{c})
+			}
+			return batches
+		}()},
+		{"twice", [][]fieldChange{changes, reversed}},
+	}
+
+	expected := "a:buy oat milk[errand],b:call mum[]"
+	for _, tst := range tt {
+		s := newStore()
+		for _, batch := range tst.batches {
+			if _, err := s.merge(batch); err != nil {
+				t.Fatalf("%s: unexpected error %v", tst.description, err)
+			}
+		}
+		if got := describe(s); got != expected {
+			t.Errorf("%s: expected %s, got %s", tst.description, expected, got)
+		}
+		if _, done := s.completed["c"]; !done {
+			t.Errorf("%s: expected a tombstone for c", tst.description)
+		}
+	}
+}
+
+func TestMergeConflicts(t *testing.T) {
+	s := newStore("task one", "task two")
+	one, two := s.snapshot(nil).tasks[0], s.snapshot(nil).tasks[1]
+	base, err := parseTimestamp(one.clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := func(ms int64, node string) timestamp {
+		return timestamp{wall: base.wall + ms, node: node}
+	}
+
+	tt := []struct {
+		description string
+		changes     []fieldChange
+		list        string
+		conflicts   []string
+	}{
+		{"edit of the current text",
+			[]fieldChange{{uid: one.uid, field: "text", value: "task 1", clock: later(10, "phone"), base: base}},
+			one.uid + ":task 1[]," + two.uid + ":task two[]", nil},
+		{"concurrent edit",
+			[]fieldChange{{uid: one.uid, field: "text", value: "task uno", clock: later(20, "laptop"), base: base}},
+			one.uid + ":task uno[]," + two.uid + ":task two[]", []string{"text task uno task 1"}},
+		{"stale concurrent edit",
+			[]fieldChange{{uid: one.uid, field: "text", value: "task eins", clock: later(15, "tablet"), base: base}},
+			one.uid + ":task uno[]," + two.uid + ":task two[]", []string{"text task uno task eins"}},
+		{"completion before an edit",
+			[]fieldChange{{uid: two.uid, field: "completed", clock: base}},
+			one.uid + ":task uno[]", []string{"completed  task two"}},
+		{"edit after completion",
+			[]fieldChange{{uid: two.uid, field: "text", value: "task 2", clock: later(30, "phone")}},
+			one.uid + ":task uno[]", []string{"text  task 2"}},
+	}
+
+	for _, tst := range tt {
+		result, err := s.merge(tst.changes)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", tst.description, err)
+		}
+		if got := describe(s); got != tst.list {
+			t.Errorf("%s: expected %s, got %s", tst.description, tst.list, got)
+		}
+
+		conflicts := []string{}
+		for _, c := range result.conflicts {
+			conflicts = append(conflicts, strings.Join([]string{c.field, c.kept, c.lost}, " "))
+		}
+		if strings.Join(conflicts, ",") != strings.Join(tst.conflicts, ",") {
+			t.Errorf("%s: expected conflicts %v, got %v", tst.description, tst.conflicts, conflicts)
+		}
+	}
+
+	if n := len(s.openConflicts()); n != 4 {
+		t.Errorf("expected 4 open conflicts, got %d", n)
+	}
+
+	defer func(saved int) { maxOpenConflicts = saved }(maxOpenConflicts)
+	maxOpenConflicts = 4
+	full := []fieldChange{{uid: one.uid, field: "text", value: "task one", clock: later(40, "phone")}}
+	if _, err := s.merge(full); err != errConflictsFull {
+		t.Errorf("expected a merge to be refused while the conflicts are full, got %v", err)
+	}
+	if got := describe(s); got != one.uid+":task uno[]" {
+		t.Errorf("expected the refused merge to change nothing, got %s", got)
+	}
+	if err := s.resolve(s.openConflicts()[0].id, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.merge(full); err != nil {
+		t.Errorf("expected a merge to be accepted once a conflict is resolved, got %v", err)
+	}
+
+	future := []fieldChange{{uid: one.uid, field: "text", value: "from the future", clock: later(int64(2*maxClockSkew/1e6), "phone")}}
+	if _, err := s.merge(future); err == nil {
+		t.Errorf("expected a change from beyond the skew bound to be refused")
+	}
+}
+
+func TestMergeConflictLimit(t *testing.T) {
+	defer func(saved int) { maxOpenConflicts = saved }(maxOpenConflicts)
+	maxOpenConflicts = 1
+	s := newStore("task one", "task two")
+	one, two := s.snapshot(nil).tasks[0], s.snapshot(nil).tasks[1]
+	base, err := parseTimestamp(one.clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale := timestamp{wall: base.wall - 1, node: "phone"}
+	version := s.version
+
+	changes := []fieldChange{
+		{uid: one.uid, field: "text", value: "task 1", clock: ts(base.wall+10, "phone"), base: stale},
+		{uid: two.uid, field: "text", value: "task 2", clock: ts(base.wall+10, "phone"), base: stale},
+		{uid: "c", field: "completed", clock: ts(base.wall+20, "phone")},
+	}
+	if _, err := s.merge(changes); err != errConflictsFull {
+		t.Fatalf("expected a merge opening two conflicts to be refused, got %v", err)
+	}
+	if got := describe(s); got != one.uid+":task one[],"+two.uid+":task two[]" {
+		t.Errorf("expected the refused merge to change nothing, got %s", got)
+	}
+	if n := len(s.openConflicts()); n != 0 {
+		t.Errorf("expected no open conflicts, got %d", n)
+	}
+	if _, done := s.completed["c"]; done {
+		t.Errorf("expected the refused merge to leave no tombstone")
+	}
+	if s.version != version {
+		t.Errorf("expected version %d, got %d", version, s.version)
+	}
+
+	if _, err := s.merge(changes[:1]); err != nil {
+		t.Errorf("expected a merge opening one conflict to be accepted, got %v", err)
+	}
+}
+
+func TestMergeTombstones(t *testing.T) {
+	defer func(saved int) { maxTombstones = saved }(maxTombstones)
+	maxTombstones = 2
+	s := newStore("task one")
+	for i, uid := range []string{"a", "b", "c"} {
+		if _, err := s.merge([]fieldChange{{uid: uid, field: "completed", clock: ts(int64(1000*(3-i)), "phone")}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.complete(1, precondition{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.completed) != 2 || len(s.buried) != 2 {
+		t.Fatalf("expected 2 tombstones, got %v", s.completed)
+	}
+	for _, uid := range []string{"a", "b"} {
+		if _, done := s.completed[uid]; done {
+			t.Errorf("expected the tombstone for %s to be forgotten", uid)
+		}
+	}
+	if _, done := s.completed["c"]; !done {
+		t.Errorf("expected a tombstone for c")
+	}
+}
+
+func TestMergeUnchanged(t *testing.T) {
+	s := newStore()
+	changes := []fieldChange{
+		{uid: "a", field: "text", value: "buy milk", clock: ts(1000, "phone")},
+		{uid: "a", field: "tags", op: "add", value: "shopping", clock: ts(2000, "phone")},
+		{uid: "a", field: "tags", op: "remove", value: "errand", clock: ts(3000, "phone")},
+	}
+	if _, err := s.merge(changes); err != nil {
+		t.Fatal(err)
+	}
+	version := s.version
+
+	for _, batch := range [][]fieldChange{changes, changes[1:], changes[2:]} {
+		result, err := s.merge(batch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.tasks) != 0 {
+			t.Errorf("expected a repeated merge to change no tasks, got %v", result.tasks)
+		}
+	}
+	if s.version != version {
+		t.Errorf("expected repeated merges to leave version %d, got %d", version, s.version)
+	}
+	if got := describe(s); got != "a:buy milk[shopping]" {
+		t.Errorf("expected a:buy milk[shopping], got %s", got)
+	}
+}
+
+func TestMergeResources(t *testing.T) {
+	usecontext(t, onetask())
+	defer func(saved int) { maxOpenConflicts = saved }(maxOpenConflicts)
+	maxOpenConflicts = 1
+	r := router()
+	store := taskctx.Value("tasks").(*taskstore)
+	one := store.snapshot(nil).tasks[0]
+	now := store.clock.tick()
+	clock := func(ms int64) string {
+		return timestamp{wall: now.wall + ms, node: "phone"}.String()
+	}
+
+	tt := []struct {
+		description string
+		method      string
+		url         string
+		contentType string
+		payload     string
+		rc          int
+		code        string
+		list        []string
+		conflicts   []string
+	}{
+		{"merge", POST, "/tasks/merge", jsonType,
+			`{"changes": [{"task": "new1", "field": "text", "value": "task two", "clock": "` + clock(1) + `"},
+				{"task": "new1", "field": "tags", "op": "add", "value": "home", "clock": "` + clock(1) + `"}]}`,
+			200, "", []string{"task one", "task two"}, nil},
+		{"concurrent edit", POST, "/tasks/merge", jsonType,
+			`{"changes": [{"task": "` + one.uid + `", "field": "text", "value": "task 1", "clock": "` + clock(2) + `", "base": "1.0.phone"}]}`,
+			200, "", []string{"task 1", "task two"}, []string{"conflict1"}},
+		{"conflicts full", POST, "/tasks/merge", jsonType,
+			`{"changes": [{"task": "new2", "field": "text", "value": "task three", "clock": "` + clock(3) + `"}]}`, 409, errTooManyConflicts, nil, nil},
+		{"form body", POST, "/tasks/merge", formType, "changes=", 415, errUnsupportedMediaType, nil, nil},
+		{"malformed json", POST, "/tasks/merge", jsonType, `{"changes": [`, 400, errInvalidBody, nil, nil},
+		{"unknown field", POST, "/tasks/merge", jsonType,
+			`{"changes": [{"task": "new2", "field": "due", "value": "monday", "clock": "` + clock(3) + `"}]}`, 400, errInvalidBody, nil, nil},
+		{"bad clock", POST, "/tasks/merge", jsonType,
+			`{"changes": [{"task": "new2", "field": "text", "value": "task three", "clock": "noon"}]}`, 400, errInvalidBody, nil, nil},
+		{"bad tag op", POST, "/tasks/merge", jsonType,
+			`{"changes": [{"task": "new2", "field": "tags", "op": "toggle", "value": "home", "clock": "` + clock(3) + `"}]}`, 400, errInvalidBody, nil, nil},
+		{"conflicts", GET, "/tasks/conflicts", "", "", 200, "", nil, []string{"conflict1"}},
+		{"resolve", POST, "/tasks/conflicts/resolve", formType, "id=conflict1&text=task+one+again", 200, "", []string{"task one again", "task two"}, []string{}},
+		{"resolve again", POST, "/tasks/conflicts/resolve", formType, "id=conflict1", 404, errConflictNotFound, nil, nil},
+		{"merge once resolved", POST, "/tasks/merge", jsonType,
+			`{"changes": [{"task": "new2", "field": "text", "value": "task three", "clock": "` + clock(3) + `"}]}`,
+			200, "", []string{"task one again", "task two", "task three"}, nil},
+		{"resolve without id", POST, "/tasks/conflicts/resolve", formType, "text=task", 400, errInvalidBody, nil, nil},
+	}
+
+	for _, tst := range tt {
+		req, err := http.NewRequest(tst.method, tst.url, strings.NewReader(tst.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tst.contentType) > 0 {
+			req.Header.Set("Content-Type", tst.contentType)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tst.rc {
+			t.Errorf("%s: expected status %d, got %d: %s", tst.description, tst.rc, w.Code, w.Body.String())
+			continue
+		}
+
+		ud, err := uber.Parse(w.Body)
+		if err != nil {
+			t.Errorf("%s: %v", tst.description, err)
+			continue
+		}
+		if errs := uber.Validate(ud); len(errs) > 0 {
+			t.Errorf("%s: invalid document: %v", tst.description, errs)
+		}
+
+		if len(tst.code) > 0 {
+			if e := uber.ErrorFromDoc(ud); e == nil || e.Code != tst.code {
+				t.Errorf("%s: expected error %s, got %v", tst.description, tst.code, e)
+			}
+			continue
+		}
+
+		if tst.list != nil {
+			texts := []string{}
+			for _, t := range store.snapshot(nil).tasks {
+				texts = append(texts, t.text)
+			}
+			if strings.Join(texts, ",") != strings.Join(tst.list, ",") {
+				t.Errorf("%s: expected tasks %v, got %v", tst.description, tst.list, texts)
+			}
+		}
+		if tst.conflicts != nil {
+			ids := []string{}
+			for _, d := range ud.FindByName("conflicts") {
+				ids = append(ids, d.ID)
+				if len(d.FindByRel("resolve")) != 1 {
+					t.Errorf("%s: expected %s to link to resolve", tst.description, d.ID)
+				}
+			}
+			if strings.Join(ids, ",") != strings.Join(tst.conflicts, ",") {
+				t.Errorf("%s: expected conflicts %v, got %v", tst.description, tst.conflicts, ids)
+			}
+		}
+	}
+
+	if tags := store.snapshot(nil).tasks[1].tags; len(tags) != 1 || tags[0] != "home" {
+		t.Errorf("expected the merged task to be tagged home, got %v", tags)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+)
+
+// savedTask is a task as the file store saves it. Tags maps each tag the task has had to the
+// timestamps of its latest add and remove.
+type savedTask struct {
+	UID   string               `json:"uid"`
+	Text  string               `json:"text"`
+	Clock string               `json:"clock"`
+	Tags  map[string][2]string `json:"tags,omitempty"`
+}
+
+// savedStore is the task list as the file store saves it. Completed holds the tombstones of
+// completed tasks, so that merges made after a restart still see them.
+type savedStore struct {
+	Tasks     []savedTask       `json:"tasks"`
+	Completed map[string]string `json:"completed"`
+}
+
+// save writes the task list to path. The list is written to a temporary file first and
+// renamed over path, so that path always holds a whole list.
+func (s *taskstore) save(path string) error {
+	s.mu.Lock()
+	saved := savedStore{Tasks: []savedTask{}, Completed: map[string]string{}}
+	for _, t := range s.tasks {
+		st := savedTask{UID: t.uid, Text: t.text, Clock: t.textClock.String(), Tags: map[string][2]string{}}
+		for tag, tc := range t.tags {
+			st.Tags[tag] = [2]string{tc.added.String(), tc.removed.String()}
+		}
+		saved.Tasks = append(saved.Tasks, st)
+	}
+	for uid, ts := range s.completed {
+		saved.Completed[uid] = ts.String()
+	}
+	s.mu.Unlock()
+
+	bs, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path+".tmp", bs, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// loadStore creates a store holding the task list saved at path, or an empty one if nothing
+// has been saved there yet.
+func loadStore(path string) (*taskstore, error) {
+	s := newStore()
+
+	bs, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var saved savedStore
+	if err := json.Unmarshal(bs, &saved); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	// timestamps parses saved timestamps, empty for none, and advances the store's clock past
+	// them, so that changes made from now on are ordered after the saved ones. A timestamp too
+	// far in the future is refused rather than left to order every later change before it.
+	timestamps := func(values ...string) ([]timestamp, error) {
+		ts := make([]timestamp, len(values))
+		for i, v := range values {
+			if len(v) == 0 {
+				continue
+			}
+			var err error
+			if ts[i], err = parseTimestamp(v); err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+			if err := s.clock.observe(ts[i]); err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			}
+		}
+		return ts, nil
+	}
+
+	for _, st := range saved.Tasks {
+		t := storedTask{uid: st.UID, text: st.Text, tags: map[string]tagClocks{}}
+		ts, err := timestamps(st.Clock)
+		if err != nil {
+			return nil, err
+		}
+		t.textClock = ts[0]
+		for tag, clocks := range st.Tags {
+			ts, err := timestamps(clocks[0], clocks[1])
+			if err != nil {
+				return nil, err
+			}
+			t.tags[tag] = tagClocks{added: ts[0], removed: ts[1]}
+		}
+		s.appendTask(t)
+	}
+	// Tombstones are buried oldest first, so that the same ones are forgotten first again.
+	buried, completed := []string{}, map[string]timestamp{}
+	for uid, v := range saved.Completed {
+		ts, err := timestamps(v)
+		if err != nil {
+			return nil, err
+		}
+		buried, completed[uid] = append(buried, uid), ts[0]
+	}
+	sort.Slice(buried, func(i, j int) bool { return completed[buried[i]].before(completed[buried[j]]) })
+	for _, uid := range buried {
+		s.tombstone(uid, completed[uid])
+	}
+	return s, nil
+}
+
+// persist saves the store to path after every change until stop is closed. Changes made
+// while a save is under way are saved together by the next.
+func (s *taskstore) persist(path string, stop <-chan struct{}, logger *log.Logger) {
+	for {
+		// The store is saved as soon as persist is watching it, in case it fell behind the
+		// feed and missed changes, or they were made before it started.
+		_, ch := s.feed.watch(0, false)
+		if err := s.save(path); err != nil {
+			logger.Printf("saving tasks to %s: %v", path, err)
+		}
+
+		for open := true; open; {
+			select {
+			case _, open = <-ch:
+				for drained := !open; !drained; {
+					select {
+					case _, open = <-ch:
+						drained = !open
+					default:
+						drained = true
+					}
+				}
+				if err := s.save(path); err != nil {
+					logger.Printf("saving tasks to %s: %v", path, err)
+				}
+			case <-stop:
+				s.feed.unwatch(ch)
+				return
+			}
+		}
+	}
+}
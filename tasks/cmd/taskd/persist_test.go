@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveAndLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "taskd-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tasks.json")
+
+	s, err := loadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.len() != 0 {
+		t.Fatalf("expected an empty store before anything is saved, got %d tasks", s.len())
+	}
+
+	s = newStore("task one", "task two", "task three")
+	s.complete(2, precondition{})
+	tagged := s.clock.tick()
+	s.merge([]fieldChange{{uid: s.tasks[0].uid, field: "tags", op: "add", value: "home", clock: tagged}})
+	if err := s.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if describe(loaded) != describe(s) {
+		t.Errorf("expected %s, got %s", describe(s), describe(loaded))
+	}
+	if len(loaded.completed) != 1 {
+		t.Errorf("expected the tombstone to be loaded, got %v", loaded.completed)
+	}
+	if ts := loaded.clock.tick(); !tagged.before(ts) {
+		t.Errorf("expected the loaded store's clock to be ahead of the saved tasks, got %s", ts)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{"tasks": [{"uid": "a", "text": "task", "clock": "noon"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadStore(path); err == nil {
+		t.Errorf("expected a malformed timestamp to be refused")
+	}
+
+	future := timestamp{wall: millis(time.Now().Add(2 * maxClockSkew)), node: "taskd"}
+	if err := ioutil.WriteFile(path, []byte(`{"completed": {"a": "`+future.String()+`"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadStore(path); err == nil || !strings.Contains(err.Error(), "too far in the future") {
+		t.Errorf("expected a timestamp beyond the skew bound to be refused, got %v", err)
+	}
+}
+
+func TestPersist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "taskd-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tasks.json")
+
+	s := newStore()
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		s.persist(path, stop, log.New(ioutil.Discard, "", 0))
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for n := 0; n < 3 && time.Now().Before(deadline); {
+		s.add("task", precondition{})
+		n++
+
+		for time.Now().Before(deadline) {
+			if loaded, err := loadStore(path); err == nil && loaded.len() == n {
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	close(stop)
+	<-done
+
+	loaded, err := loadStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.len() != 3 {
+		t.Errorf("expected 3 saved tasks, got %d", loaded.len())
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/golang.org/x/net/context"
+	"github.com/uber-apps/tasks/uber"
+)
+
+const (
+	profileURL     = "/tasks-alps.xml"
+	profileJSONURL = "/tasks-alps.json"
+
+	alpsXMLType  = "application/alps+xml"
+	alpsJSONType = "application/alps+json"
+)
+
+// field describes a semantic data element of the tasks profile.
+type field struct {
+	id  string
+	doc string
+}
+
+// transition describes a hypermedia control advertised by taskd. The links taskd sends
+// and the ALPS profile it serves are both generated from these descriptions. The URL of a
+// transition is that of the route registered under its id. Sending lists
+// the media types the transition accepts in a request body, the preferred one first, and
+// accepting lists the media types it can respond with.
+type transition struct {
+	id        string
+	name      string
+	rel       string
+	action    string
+	model     string
+	fields    []string
+	sending   []string
+	accepting []string
+	doc       string
+}
+
+var (
+	fields = []field{
+		{"text", "The text of a task."},
+		{"id", "The identifier of a task."},
+		{"count", "The number of tasks in the list."},
+		{"position", "The place of a task in the list, counting from 1."},
+		{"from", "The id a moved task had before it was moved."},
+		{"url", "The URL webhook deliveries are posted to."},
+		{"secret", "The key webhook deliveries are signed with."},
+		{"events", "The kinds of change a webhook is sent, separated by spaces."},
+		{"cursor", "Names the state of the task list a client has synced to."},
+		{"uid", "The permanent identifier of a task. Unlike its id it doesn't change when other tasks are completed."},
+		{"clock", "The hybrid logical clock timestamp of the change that set a task's text."},
+		{"tag", "A label attached to a task."},
+	}
+
+	// bodyTypes are the media types decodeArgs understands.
+	bodyTypes = []string{formType, jsonType, uberType}
+
+	// collectionTypes are the media types of the representations of the task list.
+	collectionTypes = []string{uberType, jsonType, htmlType, halType, sirenType, cjType}
+
+	// documentTypes are the media types writeDoc responds with, including for errors.
+	documentTypes = []string{uberType, htmlType}
+
+	transitions = map[string]transition{
+		"item": {id: "item", rel: "self", action: uber.ActionRead,
+			accepting: collectionTypes, doc: "Returns a single task."},
+		"list": {id: "list", name: "links", rel: "collection", action: uber.ActionRead,
+			accepting: collectionTypes, doc: "Returns the task list."},
+		"search": {id: "search", name: "links", rel: "search", action: uber.ActionRead,
+			fields: []string{"text"}, accepting: collectionTypes, doc: "Returns the tasks whose text matches."},
+		"add": {id: "add", name: "links", rel: "add", action: uber.ActionAppend,
+			model: "text={text}", fields: []string{"text"}, sending: bodyTypes, accepting: documentTypes,
+			doc: "Adds a task to the list."},
+		"metadata": {id: "metadata", name: "links", rel: "metadata", action: uber.ActionRead,
+			accepting: documentTypes, doc: "Returns information about the task list, such as its count."},
+		"events": {id: "events", name: "links", rel: "events", action: uber.ActionRead,
+			accepting: []string{eventStreamType}, doc: "Streams the changes made to the task list as server-sent events."},
+		"sync": {id: "sync", name: "links", rel: "sync", action: uber.ActionRead,
+			fields: []string{"cursor"}, accepting: documentTypes, doc: "Returns the changes made to the task list since the state named by the cursor."},
+		"complete": {id: "complete", rel: "complete", action: uber.ActionAppend,
+			model: "id={id}", fields: []string{"id"}, sending: bodyTypes, accepting: documentTypes,
+			doc: "Marks a task as completed, removing it from the list."},
+		"edit": {id: "edit", rel: "edit", action: uber.ActionReplace,
+			model: "id={id}&text={text}", fields: []string{"id", "text"}, sending: bodyTypes, accepting: documentTypes,
+			doc: "Replaces the text of a task."},
+		"move": {id: "move", rel: "move", action: uber.ActionReplace,
+			model: "id={id}&position={position}", fields: []string{"id", "position"}, sending: bodyTypes, accepting: documentTypes,
+			doc: "Moves a task to another position in the list, shifting the tasks in between."},
+		"merge": {id: "merge", name: "links", rel: "merge", action: uber.ActionAppend,
+			sending: []string{jsonType}, accepting: documentTypes,
+			doc: "Merges changes made to tasks offline, field by field."},
+		"conflicts": {id: "conflicts", name: "links", rel: "conflicts", action: uber.ActionRead,
+			accepting: documentTypes, doc: "Returns the conflicting changes merges found that need a person to resolve them."},
+		"resolve": {id: "resolve", rel: "resolve", action: uber.ActionAppend,
+			model: "id={id}&text={text}", fields: []string{"id", "text"}, sending: bodyTypes, accepting: documentTypes,
+			doc: "Resolves a conflict, giving the task a new text if one is sent."},
+		"webhooks": {id: "webhooks", name: "links", rel: "webhooks", action: uber.ActionRead,
+			accepting: documentTypes, doc: "Returns the webhook subscriptions."},
+		"subscribe": {id: "subscribe", name: "links", rel: "subscribe", action: uber.ActionAppend,
+			model: "url={url}&secret={secret}&events={events}", fields: []string{"url", "secret", "events"}, sending: bodyTypes, accepting: documentTypes,
+			doc: "Subscribes a URL to the changes made to the task list."},
+		"deadletters": {id: "deadletters", name: "links", rel: "deadletters", action: uber.ActionRead,
+			accepting: documentTypes, doc: "Returns the webhook deliveries that failed after every retry."},
+		"unsubscribe": {id: "unsubscribe", rel: "unsubscribe", action: uber.ActionAppend,
+			model: "id={id}", fields: []string{"id"}, sending: bodyTypes, accepting: documentTypes,
+			doc: "Removes a webhook subscription."},
+	}
+
+	// linkorder is the order in which the collection level transitions appear in the links block.
+	linkorder = []string{"list", "search", "add", "metadata", "events", "sync"}
+
+	// itemlinks lists the transitions attached to each task.
+	itemlinks = []string{"item", "complete", "edit", "move"}
+
+	// mergelinks is the order in which the transitions of the merge and conflict resources
+	// appear in their links blocks, after the list.
+	mergelinks = []string{"merge", "conflicts"}
+
+	// conflictlinks lists the transitions attached to each conflict.
+	conflictlinks = []string{"resolve"}
+
+	// webhooklinks is the order in which the transitions appear in the links block of the
+	// webhook resources.
+	webhooklinks = []string{"webhooks", "subscribe", "deadletters"}
+
+	// subscriptionlinks lists the transitions attached to each webhook subscription.
+	subscriptionlinks = []string{"unsubscribe"}
+)
+
+// href returns the path of the transition's target, reversed from the route registered
+// under the transition's id so that links always match the router. Values are name, value
+// pairs supplying the route's variables, e.g. "id", "task1".
+func (t transition) href(values ...string) string {
+	r := routes.Get(t.id)
+	if r == nil {
+		panic(fmt.Sprintf("no route for transition %q", t.id))
+	}
+
+	u, err := r.URLPath(values...)
+	if err != nil {
+		panic(fmt.Sprintf("cannot build the url of transition %q: %v", t.id, err))
+	}
+	return u.Path
+}
+
+// template returns the RFC 6570 URI template for a safe transition's url and fields, e.g.
+// /tasks/search{?text}. Values supply the route's variables as they do for href.
+func (t transition) template(values ...string) string {
+	if t.action != uber.ActionRead || len(t.fields) == 0 {
+		return t.href(values...)
+	}
+	return t.href(values...) + "{?" + strings.Join(t.fields, ",") + "}"
+}
+
+// link creates the Uber representation of the transition. Safe transitions that take
+// arguments are sent as URI templates, the others carry a model whose {field} placeholders
+// are filled from values.
+func (t transition) link(values ...string) *uber.DataBuilder {
+	model := t.model
+	for i := 0; i+1 < len(values); i += 2 {
+		model = strings.Replace(model, "{"+values[i]+"}", values[i+1], -1)
+	}
+
+	d := uber.NewData().Rel(t.rel).URL(t.template(values...)).Template(t.template(values...) != t.href(values...)).Action(t.action).Model(model).
+		Sending(strings.Join(t.sending, " ")).Accepting(t.accepting...)
+	if len(t.name) > 0 {
+		d.ID(t.id).Name(t.name)
+	}
+	return d
+}
+
+// alpsType maps an Uber action onto the corresponding ALPS descriptor type.
+func alpsType(action string) string {
+	switch action {
+	case uber.ActionRead:
+		return "safe"
+	case uber.ActionReplace, uber.ActionRemove:
+		return "idempotent"
+	default:
+		return "unsafe"
+	}
+}
+
+// alpsDoc represents an ALPS profile. It marshals to both the XML and JSON ALPS formats.
+type alpsDoc struct {
+	XMLName    xml.Name         `xml:"alps" json:"-"`
+	Version    string           `xml:"version,attr" json:"version"`
+	Link       []alpsLink       `xml:"link" json:"link,omitempty"`
+	Doc        *alpsText        `xml:"doc,omitempty" json:"doc,omitempty"`
+	Descriptor []alpsDescriptor `xml:"descriptor" json:"descriptor,omitempty"`
+}
+
+type alpsLink struct {
+	Rel  string `xml:"rel,attr" json:"rel"`
+	Href string `xml:"href,attr" json:"href"`
+}
+
+type alpsText struct {
+	Value string `xml:",chardata" json:"value"`
+}
+
+type alpsDescriptor struct {
+	ID         string           `xml:"id,attr,omitempty" json:"id,omitempty"`
+	Href       string           `xml:"href,attr,omitempty" json:"href,omitempty"`
+	Type       string           `xml:"type,attr,omitempty" json:"type,omitempty"`
+	Doc        *alpsText        `xml:"doc,omitempty" json:"doc,omitempty"`
+	Descriptor []alpsDescriptor `xml:"descriptor" json:"descriptor,omitempty"`
+}
+
+// mkProfile creates the ALPS profile describing the fields and transitions taskd advertises.
+func mkProfile() alpsDoc {
+	profile := alpsDoc{
+		Version: "1.0",
+		Link: []alpsLink{
+			{Rel: "author", Href: "http://github.com/mamund/"},
+			{Rel: "source", Href: "https://github.com/mamund/media-types/tree/master/uber-apps/tasks"},
+		},
+		Doc: &alpsText{"Profile for simple hypermedia task list demo app."},
+	}
+
+	for _, f := range fields {
+		profile.Descriptor = append(profile.Descriptor, alpsDescriptor{ID: f.id, Type: "semantic", Doc: &alpsText{f.doc}})
+	}
+
+	for _, ids := range [][]string{linkorder, itemlinks, mergelinks, conflictlinks, webhooklinks, subscriptionlinks} {
+		for _, id := range ids {
+			t := transitions[id]
+			d := alpsDescriptor{ID: t.id, Type: alpsType(t.action), Doc: &alpsText{t.doc}}
+			for _, f := range t.fields {
+				d.Descriptor = append(d.Descriptor, alpsDescriptor{Href: "#" + f})
+			}
+			profile.Descriptor = append(profile.Descriptor, d)
+		}
+	}
+
+	return profile
+}
+
+// alpsprofile responds with the ALPS profile. The JSON form is sent when requested by path or
+// preferred by the Accept header, otherwise the XML form is sent.
+func alpsprofile(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	var (
+		bs  []byte
+		err error
+		ct  string
+	)
+
+	if req.URL.Path == profileJSONURL || negotiate(req.Header.Get("Accept"), []string{alpsXMLType, alpsJSONType}) == alpsJSONType {
+		ct = alpsJSONType
+		bs, err = json.Marshal(struct {
+			Alps alpsDoc `json:"alps"`
+		}{mkProfile()})
+	} else {
+		ct = alpsXMLType
+		bs, err = xml.MarshalIndent(mkProfile(), "", "  ")
+		bs = append([]byte(xml.Header), bs...)
+	}
+
+	if err != nil {
+		writeError(w, req, newError(errEncodingFailed, "Cannot generate ALPS profile"))
+		return
+	}
+
+	w.Header().Set("Content-Type", ct)
+	w.WriteHeader(http.StatusOK)
+	w.Write(bs)
+}
+
+// profileLink advertises the ALPS profile, via a Link header, on every response from h.
+func profileLink(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"profile\"", profileURL))
+		h.ServeHTTP(w, req)
+	})
+}
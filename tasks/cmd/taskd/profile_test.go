@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/uber-apps/tasks/uber"
+)
+
+func TestProfile(t *testing.T) {
+	tt := []struct {
+		description string
+		path        string
+		accept      string
+		ct          string
+	}{
+		{"xml profile", profileURL, "", alpsXMLType},
+		{"json profile by path", profileJSONURL, "", alpsJSONType},
+		{"json profile by accept", profileURL, alpsJSONType, alpsJSONType},
+	}
+
+	h := profileLink(router())
+	for _, tst := range tt {
+		req, err := http.NewRequest(GET, tst.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", tst.accept)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: Response Code mismatch: expected %d, got %d", tst.description, http.StatusOK, w.Code)
+			continue
+		}
+
+		if ct := w.Header().Get("Content-Type"); ct != tst.ct {
+			t.Errorf("%s: Content-Type mismatch: expected %s, got %s", tst.description, tst.ct, ct)
+		}
+
+		if link := w.Header().Get("Link"); link != `</tasks-alps.xml>; rel="profile"` {
+			t.Errorf("%s: missing profile Link header, got %q", tst.description, link)
+		}
+
+		var profile alpsDoc
+		if tst.ct == alpsJSONType {
+			var doc struct {
+				Alps alpsDoc `json:"alps"`
+			}
+			err = json.Unmarshal(w.Body.Bytes(), &doc)
+			profile = doc.Alps
+		} else {
+			err = xml.Unmarshal(w.Body.Bytes(), &profile)
+		}
+		if err != nil {
+			t.Errorf("%s: cannot decode profile: %v", tst.description, err)
+			continue
+		}
+
+		expected := len(fields) + len(linkorder) + len(itemlinks) + len(mergelinks) + len(conflictlinks) + len(webhooklinks) + len(subscriptionlinks)
+		if len(profile.Descriptor) != expected {
+			t.Errorf("%s: expected %d descriptors, got %d", tst.description, expected, len(profile.Descriptor))
+		}
+	}
+}
+
+func TestProfileMatchesLinks(t *testing.T) {
+	described := map[string]bool{}
+	for _, d := range mkProfile().Descriptor {
+		described[d.ID] = true
+	}
+
+	resp := mkEmptylist()
+	appendItem(resp, "task1", "task one")
+
+	for _, rel := range []string{"collection", "search", "add", "complete"} {
+		for _, d := range resp.FindByRel(rel) {
+			found := false
+			for id, tr := range transitions {
+				if tr.rel == rel && described[id] {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("link with rel %q at %s is not described by the profile", rel, d.URL)
+			}
+		}
+	}
+}
+
+func TestTransitionMediaTypes(t *testing.T) {
+	if len(collectionTypes) != len(representations) {
+		t.Errorf("expected %d collection types, got %d", len(representations), len(collectionTypes))
+	}
+	for i, r := range representations {
+		if i < len(collectionTypes) && collectionTypes[i] != r.mediaType {
+			t.Errorf("expected collection type %d to be %s, got %s", i, r.mediaType, collectionTypes[i])
+		}
+	}
+
+	for id, tr := range transitions {
+		if len(tr.accepting) == 0 {
+			t.Errorf("%s: no accepting media types", id)
+		}
+		if (tr.action == uber.ActionRead) != (len(tr.sending) == 0) {
+			t.Errorf("%s: %s transition has sending media types %v", id, tr.action, tr.sending)
+		}
+	}
+}
@@ -0,0 +1,590 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/golang.org/x/net/context"
+	"github.com/uber-apps/tasks/uber"
+)
+
+const (
+	uberType  = "application/vnd.uber+json"
+	jsonType  = "application/json"
+	halType   = "application/hal+json"
+	sirenType = "application/vnd.siren+json"
+	cjType    = "application/vnd.collection+json"
+	htmlType  = "text/html"
+	formType  = "application/x-www-form-urlencoded"
+)
+
+// task is the format neutral model of a single task. Besides its id, which is its position
+// in the list, a task has a uid that never changes. Clock is the timestamp of the task's text
+// and tags its tags, in order. Only sync and merge responses show them.
+type task struct {
+	id    string
+	text  string
+	uid   string
+	clock string
+	tags  []string
+}
+
+// collection is the format neutral model of a task list. The transitions that accompany it
+// are the ones named in linkorder and, for each task, itemlinks. Its entity is the store, or
+// the single task, it was taken from.
+//
+// A collection holds its tasks either as copies in tasks or, when taken from the whole store
+// by list, as the store's own tasks, those satisfying match, which are only turned into the
+// format neutral model one at a time by each.
+type collection struct {
+	entity
+	tasks  []task
+	stored []*storedTask
+	match  func(text string) bool
+}
+
+// each calls f with each of the collection's tasks in order.
+func (c collection) each(f func(task)) {
+	for _, t := range c.tasks {
+		f(t)
+	}
+	for i, t := range c.stored {
+		if c.match == nil || c.match(t.text) {
+			f(t.view(i + 1))
+		}
+	}
+}
+
+// encoder encodes a collection in a particular hypermedia format. The request that asked for
+// the collection, and its context, let the encoder honor per request options.
+type encoder func(context.Context, *http.Request, collection) ([]byte, error)
+
+// streamer encodes a collection like an encoder, but writes the encoding to w as it goes
+// rather than building it in memory first.
+type streamer func(context.Context, *http.Request, collection, io.Writer) error
+
+// representation renders a collection in a particular hypermedia format. Formats that can be
+// streamed have a streamer, which must write what the encoder returns.
+type representation struct {
+	mediaType string
+	encode    encoder
+	stream    streamer
+}
+
+// representations lists the formats taskd can produce in order of preference.
+var representations = []representation{
+	{uberType, encodeUber, streamUber},
+	{jsonType, encodeUber, streamUber},
+	{htmlType, encodeHTML, nil},
+	{halType, encodeJSON(halCollection), nil},
+	{sirenType, encodeJSON(sirenCollection), nil},
+	{cjType, encodeJSON(cjCollection), nil},
+}
+
+// streamBufferSize is the size of the buffer streamed responses are written through.
+const streamBufferSize = 32 << 10
+
+// encodeJSON adapts a function that models a collection in some JSON based format into an
+// encoder for that format.
+func encodeJSON(render func(collection) interface{}) encoder {
+	return func(ctx context.Context, req *http.Request, c collection) ([]byte, error) {
+		return json.Marshal(render(c))
+	}
+}
+
+// encodeUber encodes the collection as an Uber document, transcluding any linked resources
+// the request asked to have expanded.
+func encodeUber(ctx context.Context, req *http.Request, c collection) ([]byte, error) {
+	ud := uberCollection(c).(*uber.Doc)
+	transclude(ctx, req, ud)
+	return json.Marshal(ud)
+}
+
+// placeholder is the encoding of the element streamUber puts in the tasks container of the
+// document it encodes to mark where the tasks go.
+const placeholder = `{"id":"\u0000"}`
+
+// streamUber writes the collection as the Uber document encodeUber encodes, without
+// transcluding anything. The document is encoded without its tasks, with a placeholder in
+// their container, and the tasks are encoded one at a time in its place.
+func streamUber(ctx context.Context, req *http.Request, c collection, w io.Writer) error {
+	skeleton := mkEmptylist()
+	tasks := skeleton.FindByID("tasks")
+	tasks.Data = append(tasks.Data, uber.NewData().ID("\x00").Build())
+
+	bs, err := json.Marshal(skeleton)
+	if err != nil {
+		return err
+	}
+
+	open := []byte(`,"data":[` + placeholder)
+	i := bytes.Index(bs, open)
+	if i < 0 {
+		return errors.New("cannot find the tasks in the encoded document")
+	}
+	head, tail := bs[:i], bs[i+len(open)+len("]"):]
+
+	if _, err := w.Write(head); err != nil {
+		return err
+	}
+
+	n := 0
+	c.each(func(t task) {
+		if err != nil {
+			return
+		}
+
+		var item []byte
+		if item, err = json.Marshal(taskData(t.id, t.text, itemlinks).Build()); err != nil {
+			return
+		}
+		sep := ","
+		if n == 0 {
+			sep = `,"data":[`
+		}
+		if _, err = io.WriteString(w, sep); err == nil {
+			_, err = w.Write(item)
+		}
+		n++
+	})
+	if err != nil {
+		return err
+	}
+
+	if n > 0 {
+		if _, err := io.WriteString(w, "]"); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(tail)
+	return err
+}
+
+// encodeHTML renders the collection's Uber document as an HTML page.
+func encodeHTML(ctx context.Context, req *http.Request, c collection) ([]byte, error) {
+	ud := uberCollection(c).(*uber.Doc)
+	transclude(ctx, req, ud)
+	return renderHTML(ud)
+}
+
+// uberCollection renders the collection as an Uber document.
+func uberCollection(c collection) interface{} {
+	resp := mkEmptylist()
+	c.each(func(t task) {
+		appendItem(resp, t.id, t.text)
+	})
+	return resp
+}
+
+// writeCollection responds with the collection rendered in the format preferred by the
+// request's Accept header.
+func writeCollection(ctx context.Context, w http.ResponseWriter, req *http.Request, c collection) {
+	offers := []string{}
+	for _, r := range representations {
+		offers = append(offers, r.mediaType)
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	mt := negotiate(req.Header.Get("Accept"), offers)
+	if len(mt) == 0 {
+		writeError(w, req, newError(errNotAcceptable, "No acceptable representation of the task list"))
+		return
+	}
+
+	for _, r := range representations {
+		if r.mediaType != mt {
+			continue
+		}
+
+		if r.stream != nil && requestedExpansion(req).none() {
+			streamCollection(ctx, w, req, r, c)
+			return
+		}
+
+		bs, err := r.encode(ctx, req, c)
+		if err != nil {
+			writeError(w, req, newError(errEncodingFailed, "Cannot encode task list"))
+			return
+		}
+
+		if mt == htmlType {
+			mt += "; charset=utf-8"
+		}
+
+		tag := c.tag(r.mediaType)
+		w.Header().Set("ETag", tag)
+		if notModified(req, tag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", mt)
+		w.WriteHeader(http.StatusOK)
+		w.Write(bs)
+		return
+	}
+}
+
+// streamCollection responds with the collection as written by r's streamer. The collection
+// iterates over the list as it was at c.version, which later changes leave alone, and its
+// entity tag is derived from that state rather than from the body, which is written once.
+// The status is sent before the body, so a stream that fails aborts the connection rather
+// than leave the client with a truncated list it would take for the whole.
+func streamCollection(ctx context.Context, w http.ResponseWriter, req *http.Request, r representation, c collection) {
+	tag := c.tag(r.mediaType)
+	w.Header().Set("ETag", tag)
+	if notModified(req, tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", r.mediaType)
+	w.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriterSize(w, streamBufferSize)
+	if err := r.stream(ctx, req, c, bw); err != nil {
+		ctx.Value("logger").(*log.Logger).Printf("streaming the task list: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+	bw.Flush()
+}
+
+// negotiate returns the offered media type the Accept header value prefers, or the empty
+// string if none is acceptable. Offers earlier in the list win ties, and an empty Accept
+// header accepts the first offer.
+func negotiate(accept string, offers []string) string {
+	if len(strings.TrimSpace(accept)) == 0 {
+		if len(offers) == 0 {
+			return ""
+		}
+		return offers[0]
+	}
+
+	type mediaRange struct {
+		typ, subtype string
+		q            float64
+	}
+
+	ranges := []mediaRange{}
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mr := mediaRange{q: 1}
+
+		ts := strings.SplitN(strings.TrimSpace(params[0]), "/", 2)
+		if len(ts) != 2 {
+			continue
+		}
+		mr.typ, mr.subtype = strings.ToLower(ts[0]), strings.ToLower(ts[1])
+
+		for _, p := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) == 2 && strings.ToLower(kv[0]) == "q" {
+				if q, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					mr.q = q
+				}
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+
+	best, bestq := "", 0.0
+	for _, offer := range offers {
+		ts := strings.SplitN(offer, "/", 2)
+
+		// The most specific range that matches the offer determines its quality.
+		q, specificity := 0.0, -1
+		for _, mr := range ranges {
+			s := -1
+			switch {
+			case mr.typ == ts[0] && mr.subtype == ts[1]:
+				s = 2
+			case mr.typ == ts[0] && mr.subtype == "*":
+				s = 1
+			case mr.typ == "*" && mr.subtype == "*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = mr.q, s
+			}
+		}
+
+		if q > bestq {
+			best, bestq = offer, q
+		}
+	}
+
+	return best
+}
+
+// halCollection renders the collection as HAL+JSON. Safe transitions become links, templated
+// where they take arguments, and unsafe transitions become HAL-FORMS templates.
+func halCollection(c collection) interface{} {
+	type halLink struct {
+		Href      string `json:"href"`
+		Templated bool   `json:"templated,omitempty"`
+	}
+
+	type halProperty struct {
+		Name     string `json:"name"`
+		Required bool   `json:"required"`
+		Value    string `json:"value,omitempty"`
+	}
+
+	type halTemplate struct {
+		Title       string        `json:"title,omitempty"`
+		Method      string        `json:"method"`
+		Target      string        `json:"target"`
+		ContentType string        `json:"contentType"`
+		Properties  []halProperty `json:"properties"`
+	}
+
+	type halItem struct {
+		Links     map[string]halLink     `json:"_links"`
+		Templates map[string]halTemplate `json:"_templates,omitempty"`
+		ID        string                 `json:"id"`
+		Text      string                 `json:"text"`
+	}
+
+	type halDoc struct {
+		Links     map[string]halLink     `json:"_links"`
+		Templates map[string]halTemplate `json:"_templates,omitempty"`
+		Embedded  map[string][]halItem   `json:"_embedded"`
+	}
+
+	addTransition := func(links map[string]halLink, templates map[string]halTemplate, t transition, values map[string]string) {
+		if t.action == uber.ActionRead {
+			links[t.rel] = halLink{Href: t.template("id", values["id"]), Templated: len(t.fields) > 0}
+			return
+		}
+
+		tpl := halTemplate{Title: t.doc, Method: uber.Method(t.action), Target: t.href(), ContentType: t.sending[0], Properties: []halProperty{}}
+		for _, f := range t.fields {
+			tpl.Properties = append(tpl.Properties, halProperty{Name: f, Required: true, Value: values[f]})
+		}
+		templates[t.id] = tpl
+	}
+
+	doc := halDoc{
+		Links:     map[string]halLink{"self": {Href: transitions["list"].href()}, "profile": {Href: profileURL}},
+		Templates: map[string]halTemplate{},
+		Embedded:  map[string][]halItem{"item": []halItem{}},
+	}
+
+	for _, id := range linkorder {
+		addTransition(doc.Links, doc.Templates, transitions[id], nil)
+	}
+
+	c.each(func(t task) {
+		item := halItem{Links: map[string]halLink{"profile": {Href: profileURL}}, Templates: map[string]halTemplate{}, ID: t.id, Text: t.text}
+		for _, id := range itemlinks {
+			addTransition(item.Links, item.Templates, transitions[id], map[string]string{"id": t.id})
+		}
+		doc.Embedded["item"] = append(doc.Embedded["item"], item)
+	})
+
+	return doc
+}
+
+// sirenCollection renders the collection as Siren. Safe transitions without arguments become
+// links and every other transition becomes an action with fields.
+func sirenCollection(c collection) interface{} {
+	type sirenField struct {
+		Name  string `json:"name"`
+		Type  string `json:"type"`
+		Value string `json:"value,omitempty"`
+	}
+
+	type sirenAction struct {
+		Name   string       `json:"name"`
+		Title  string       `json:"title,omitempty"`
+		Method string       `json:"method"`
+		Href   string       `json:"href"`
+		Type   string       `json:"type,omitempty"`
+		Fields []sirenField `json:"fields"`
+	}
+
+	type sirenLink struct {
+		Rel  []string `json:"rel"`
+		Href string   `json:"href"`
+	}
+
+	type sirenEntity struct {
+		Class      []string          `json:"class"`
+		Rel        []string          `json:"rel,omitempty"`
+		Properties map[string]string `json:"properties,omitempty"`
+		Entities   []sirenEntity     `json:"entities,omitempty"`
+		Actions    []sirenAction     `json:"actions,omitempty"`
+		Links      []sirenLink       `json:"links"`
+	}
+
+	addTransition := func(e *sirenEntity, t transition, values map[string]string) {
+		if t.action == uber.ActionRead && len(t.fields) == 0 {
+			e.Links = append(e.Links, sirenLink{Rel: []string{t.rel}, Href: t.href("id", values["id"])})
+			return
+		}
+
+		a := sirenAction{Name: t.id, Title: t.doc, Method: uber.Method(t.action), Href: t.href(), Fields: []sirenField{}}
+		if len(t.sending) > 0 {
+			a.Type = t.sending[0]
+		}
+		for _, f := range t.fields {
+			if v, ok := values[f]; ok {
+				a.Fields = append(a.Fields, sirenField{Name: f, Type: "hidden", Value: v})
+			} else {
+				a.Fields = append(a.Fields, sirenField{Name: f, Type: "text"})
+			}
+		}
+		e.Actions = append(e.Actions, a)
+	}
+
+	doc := sirenEntity{
+		Class:    []string{"tasks", "collection"},
+		Entities: []sirenEntity{},
+		Links:    []sirenLink{{Rel: []string{"self"}, Href: transitions["list"].href()}, {Rel: []string{"profile"}, Href: profileURL}},
+	}
+
+	for _, id := range linkorder {
+		addTransition(&doc, transitions[id], nil)
+	}
+
+	c.each(func(t task) {
+		e := sirenEntity{
+			Class:      []string{"task"},
+			Rel:        []string{"item"},
+			Properties: map[string]string{"id": t.id, "text": t.text},
+			Links:      []sirenLink{{Rel: []string{"profile"}, Href: profileURL}},
+		}
+		for _, id := range itemlinks {
+			addTransition(&e, transitions[id], map[string]string{"id": t.id})
+		}
+		doc.Entities = append(doc.Entities, e)
+	})
+
+	return doc
+}
+
+// cjCollection renders the collection as Collection+JSON. Safe transitions with arguments
+// become queries and the add transition becomes the write template. Collection+JSON has no
+// per item actions so each item's transitions are rendered as links carrying the item's id.
+func cjCollection(c collection) interface{} {
+	type cjData struct {
+		Name   string `json:"name"`
+		Value  string `json:"value"`
+		Prompt string `json:"prompt,omitempty"`
+	}
+
+	type cjLink struct {
+		Rel    string `json:"rel"`
+		Href   string `json:"href"`
+		Name   string `json:"name,omitempty"`
+		Prompt string `json:"prompt,omitempty"`
+	}
+
+	type cjQuery struct {
+		Rel    string   `json:"rel"`
+		Href   string   `json:"href"`
+		Name   string   `json:"name,omitempty"`
+		Prompt string   `json:"prompt,omitempty"`
+		Data   []cjData `json:"data"`
+	}
+
+	type cjItem struct {
+		Href  string   `json:"href,omitempty"`
+		Data  []cjData `json:"data"`
+		Links []cjLink `json:"links,omitempty"`
+	}
+
+	type cjTemplate struct {
+		Data []cjData `json:"data"`
+	}
+
+	type cjBody struct {
+		Version  string      `json:"version"`
+		Href     string      `json:"href"`
+		Links    []cjLink    `json:"links"`
+		Items    []cjItem    `json:"items"`
+		Queries  []cjQuery   `json:"queries,omitempty"`
+		Template *cjTemplate `json:"template,omitempty"`
+	}
+
+	body := cjBody{
+		Version: "1.0",
+		Href:    transitions["list"].href(),
+		Links:   []cjLink{{Rel: "profile", Href: profileURL}},
+		Items:   []cjItem{},
+	}
+
+	for _, id := range linkorder {
+		t := transitions[id]
+		switch {
+		case t.action == uber.ActionRead && len(t.fields) > 0:
+			q := cjQuery{Rel: t.rel, Href: t.href(), Name: t.id, Prompt: t.doc, Data: []cjData{}}
+			for _, f := range t.fields {
+				q.Data = append(q.Data, cjData{Name: f})
+			}
+			body.Queries = append(body.Queries, q)
+		case t.id == "add":
+			body.Template = &cjTemplate{Data: []cjData{}}
+			for _, f := range t.fields {
+				body.Template.Data = append(body.Template.Data, cjData{Name: f})
+			}
+		case t.href() != body.Href:
+			body.Links = append(body.Links, cjLink{Rel: t.rel, Href: t.href(), Name: t.id, Prompt: t.doc})
+		}
+	}
+
+	c.each(func(t task) {
+		item := cjItem{Data: []cjData{{Name: "id", Value: t.id}, {Name: "text", Value: t.text}}}
+		for _, id := range itemlinks {
+			tr := transitions[id]
+			if tr.rel == "self" {
+				item.Href = tr.href("id", t.id)
+				continue
+			}
+			item.Links = append(item.Links, cjLink{Rel: tr.rel, Href: tr.href("id", t.id), Name: t.id, Prompt: tr.doc})
+		}
+		body.Items = append(body.Items, item)
+	})
+
+	return struct {
+		Collection cjBody `json:"collection"`
+	}{body}
+}
+
+// writeDoc responds with an Uber document, rendered as an HTML page for clients that prefer
+// HTML.
+func writeDoc(w http.ResponseWriter, req *http.Request, status int, ud *uber.Doc) {
+	var (
+		bs  []byte
+		err error
+		ct  = uberType
+	)
+
+	if prefersHTML(req) {
+		bs, err = renderHTML(ud)
+		ct = htmlType + "; charset=utf-8"
+	} else {
+		bs, err = json.Marshal(ud)
+	}
+
+	if err != nil {
+		if len(ud.Uber.Error) > 0 {
+			http.Error(w, "Cannot encode error document", http.StatusInternalServerError)
+			return
+		}
+		writeError(w, req, newError(errEncodingFailed, "Cannot encode response"))
+		return
+	}
+
+	w.Header().Set("Content-Type", ct)
+	w.WriteHeader(status)
+	w.Write(bs)
+}
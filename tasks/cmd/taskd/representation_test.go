@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/golang.org/x/net/context"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{uberType, jsonType, halType, sirenType, cjType}
+
+	tt := []struct {
+		accept   string
+		expected string
+	}{
+		{"", uberType},
+		{"*/*", uberType},
+		{"application/json", jsonType},
+		{"application/hal+json", halType},
+		{"application/hal+json;q=0.5, application/vnd.siren+json", sirenType},
+		{"application/vnd.collection+json, */*;q=0.1", cjType},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", uberType},
+		{"application/*;q=0.2, application/hal+json;q=0", uberType},
+		{"text/plain", ""},
+	}
+
+	for _, tst := range tt {
+		if mt := negotiate(tst.accept, offers); mt != tst.expected {
+			t.Errorf("negotiate(%q): expected %q, got %q", tst.accept, tst.expected, mt)
+		}
+	}
+}
+
+func TestRepresentations(t *testing.T) {
+	tt := []struct {
+		description string
+		accept      string
+		rc          int
+		check       func(body map[string]interface{}) bool
+	}{
+		{"uber", uberType, 200, func(b map[string]interface{}) bool {
+			return b["uber"] != nil
+		}},
+		{"hal", halType, 200, func(b map[string]interface{}) bool {
+			links := b["_links"].(map[string]interface{})
+			search := links["search"].(map[string]interface{})
+			items := b["_embedded"].(map[string]interface{})["item"].([]interface{})
+			templates := b["_templates"].(map[string]interface{})
+			return search["href"] == "/tasks/search{?text}" && search["templated"] == true &&
+				templates["add"] != nil && len(items) == 3
+		}},
+		{"siren", sirenType, 200, func(b map[string]interface{}) bool {
+			entities := b["entities"].([]interface{})
+			actions := b["actions"].([]interface{})
+			first := entities[0].(map[string]interface{})
+			complete := first["actions"].([]interface{})[0].(map[string]interface{})
+			return len(entities) == 3 && len(actions) == 3 && complete["name"] == "complete" && complete["method"] == "POST"
+		}},
+		{"collection+json", cjType, 200, func(b map[string]interface{}) bool {
+			c := b["collection"].(map[string]interface{})
+			items := c["items"].([]interface{})
+			queries := c["queries"].([]interface{})
+			return c["template"] != nil && len(items) == 3 && len(queries) == 2
+		}},
+		{"unacceptable", "text/plain", 406, nil},
+	}
+
+	for _, tst := range tt {
+		req, err := http.NewRequest(GET, "/tasks", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", tst.accept)
+
+		w := httptest.NewRecorder()
+		tasklist(multipletasks(), w, req)
+
+		if w.Code != tst.rc {
+			t.Errorf("%s: Response Code mismatch: expected %d, got %d", tst.description, tst.rc, w.Code)
+			continue
+		}
+
+		if tst.check == nil {
+			continue
+		}
+
+		if ct := w.Header().Get("Content-Type"); ct != tst.accept {
+			t.Errorf("%s: Content-Type mismatch: expected %s, got %s", tst.description, tst.accept, ct)
+		}
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s: cannot decode body: %v", tst.description, err)
+			continue
+		}
+
+		if !tst.check(body) {
+			t.Errorf("%s: unexpected body %s", tst.description, w.Body.String())
+		}
+	}
+}
+
+func TestStreamUber(t *testing.T) {
+	usecontext(t, notasks())
+	router()
+
+	tt := []struct {
+		description string
+		store       *taskstore
+		match       func(text string) bool
+	}{
+		{"no tasks", newStore(), nil},
+		{"one task", newStore("task one"), nil},
+		{"several tasks", newStore("task one", "task <two> & \"three\"", "task four"), nil},
+		{"no match", newStore("task one", "task two"), func(text string) bool { return text == "task three" }},
+		{"some match", newStore("task one", "task two", "task one"), func(text string) bool { return text == "task one" }},
+	}
+
+	for _, tst := range tt {
+		req, err := http.NewRequest(GET, "/tasks", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected, err := encodeUber(taskctx, req, tst.store.snapshot(tst.match))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var b bytes.Buffer
+		if err := streamUber(taskctx, req, tst.store.list(tst.match), &b); err != nil {
+			t.Errorf("%s: unexpected error %v", tst.description, err)
+			continue
+		}
+		if b.String() != string(expected) {
+			t.Errorf("%s: expected\n%s\ngot\n%s", tst.description, expected, b.String())
+		}
+	}
+}
+
+func TestStreamCollectionTag(t *testing.T) {
+	usecontext(t, notasks())
+	router()
+	store := newStore("task one", "task two")
+
+	streams := 0
+	r := representation{mediaType: uberType, stream: func(ctx context.Context, req *http.Request, c collection, w io.Writer) error {
+		streams++
+		return streamUber(ctx, req, c, w)
+	}}
+	get := func(tag string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(GET, "/tasks", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tag) > 0 {
+			req.Header.Set("If-None-Match", tag)
+		}
+		w := httptest.NewRecorder()
+		streamCollection(taskctx, w, req, r, store.list(nil))
+		return w
+	}
+
+	tag := get("").Header().Get("ETag")
+	if streams != 1 || tag != store.list(nil).tag(uberType) {
+		t.Fatalf("expected the list to be streamed once with the tag of version %d, got %d streams and %s", store.version, streams, tag)
+	}
+	if w := get(tag); w.Code != http.StatusNotModified || streams != 1 {
+		t.Errorf("expected an unchanged list not to be streamed, got %d after %d streams", w.Code, streams)
+	}
+
+	store.add("task three", precondition{})
+	if w := get(tag); w.Code != http.StatusOK || w.Header().Get("ETag") == tag {
+		t.Errorf("expected a changed list to get a new tag, got %d %s", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+// chunkRecorder is a ResponseWriter that keeps only the size of the body written to it and
+// of the largest single write. Every so many writes it also collects garbage and keeps the
+// largest live heap it finds, so that what the response holds on to can be told from what
+// it has finished with.
+type chunkRecorder struct {
+	header  http.Header
+	code    int
+	size    int
+	largest int
+	writes  int
+	heap    uint64
+}
+
+func (cr *chunkRecorder) Header() http.Header  { return cr.header }
+func (cr *chunkRecorder) WriteHeader(code int) { cr.code = code }
+
+func (cr *chunkRecorder) Write(b []byte) (int, error) {
+	cr.size += len(b)
+	if len(b) > cr.largest {
+		cr.largest = len(b)
+	}
+	if cr.writes%100 == 0 {
+		cr.heap = maxUint64(cr.heap, liveHeap())
+	}
+	cr.writes++
+	return len(b), nil
+}
+
+// liveHeap returns the bytes the heap holds after collecting garbage.
+func liveHeap() uint64 {
+	runtime.GC()
+	ms := runtime.MemStats{}
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc
+}
+
+func maxUint64(a, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func TestStreamLargeList(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the large list in short mode")
+	}
+
+	// stream lists n tasks and returns the response and how much the live heap grew while it
+	// was being written.
+	stream := func(n int) (*chunkRecorder, int64) {
+		store := newStore()
+		for i := 0; i < n; i++ {
+			store.add(fmt.Sprintf("task %d", i), precondition{})
+		}
+		usecontext(t, context.WithValue(notasks(), "tasks", store))
+		r := router()
+
+		req, err := http.NewRequest(GET, "/tasks", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cr := &chunkRecorder{header: http.Header{}}
+		before := liveHeap()
+		r.ServeHTTP(cr, req)
+
+		if cr.code != http.StatusOK {
+			t.Fatalf("%d tasks: expected status 200, got %d", n, cr.code)
+		}
+		if cr.largest > streamBufferSize {
+			t.Errorf("%d tasks: expected writes of at most %d bytes, got one of %d", n, streamBufferSize, cr.largest)
+		}
+		if tag := store.list(nil).tag(uberType); cr.header.Get("ETag") != tag {
+			t.Errorf("%d tasks: expected ETag %s, got %s", n, tag, cr.header.Get("ETag"))
+		}
+		if n <= 1000 {
+			bs, err := encodeUber(taskctx, req, store.snapshot(nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cr.size != len(bs) {
+				t.Errorf("%d tasks: expected a body of %d bytes, got %d", n, len(bs), cr.size)
+			}
+		}
+		return cr, int64(cr.heap) - int64(before)
+	}
+
+	_, small := stream(1000)
+	cr, large := stream(100000)
+	if large > small+1<<20 {
+		t.Errorf("expected the heap to grow by about %d bytes, as it does for 1000 tasks, while streaming a %d byte list of 100000, it grew by %d", small, cr.size, large)
+	}
+}
+
+func TestStreamAbort(t *testing.T) {
+	usecontext(t, notasks())
+	router()
+	store := newStore("task one", "task two")
+
+	r := representation{mediaType: uberType, stream: func(ctx context.Context, req *http.Request, c collection, w io.Writer) error {
+		io.WriteString(w, `{"uber":`)
+		return errors.New("disk on fire")
+	}}
+	req, err := http.NewRequest(GET, "/tasks", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("expected a failed stream to abort the response, got %v", p)
+		}
+	}()
+	streamCollection(taskctx, httptest.NewRecorder(), req, r, store.list(nil))
+}
+
+func TestListIsolation(t *testing.T) {
+	s := newStore("task one", "task two", "task three")
+	l := s.list(nil)
+
+	s.edit(1, "task 1", precondition{})
+	s.complete(2, precondition{})
+	s.add("task four", precondition{})
+	s.merge([]fieldChange{{uid: s.tasks[0].uid, field: "tags", op: "add", value: "home", clock: s.clock.tick()}})
+
+	texts := []string{}
+	l.each(func(t task) {
+		texts = append(texts, fmt.Sprintf("%s:%s%v", t.id, t.text, t.tags))
+	})
+	if expected := "task1:task one[],task2:task two[],task3:task three[]"; strings.Join(texts, ",") != expected {
+		t.Errorf("expected the list to stay %s, got %s", expected, strings.Join(texts, ","))
+	}
+	if l.version != 3 {
+		t.Errorf("expected version 3, got %d", l.version)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/github.com/gorilla/mux"
+	"github.com/uber-apps/tasks/uber"
+)
+
+func TestAdvertisedLinksResolve(t *testing.T) {
+	ud := mkEmptylist()
+	appendItem(ud, "task1", "task one")
+	e := newError(errTaskNotFound, "No such task")
+	ud.Uber.Error = e.Doc().Uber.Error
+
+	checked := 0
+	ud.Walk(func(path string, d *uber.Data) error {
+		if len(d.URL) == 0 {
+			return nil
+		}
+
+		target := d.URL
+		if d.Template {
+			target = uber.MustParseTemplate(d.URL).Expand(map[string]string{"text": "task"})
+		}
+
+		req, err := http.NewRequest(uber.Method(d.Action), target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var match mux.RouteMatch
+		if !routes.Match(req, &match) {
+			t.Errorf("%s: %s %s does not match any route", path, req.Method, d.URL)
+		}
+		checked++
+		return nil
+	})
+
+	if expected := 1 + len(linkorder) + len(itemlinks) + 1; checked != expected {
+		t.Errorf("expected %d links to be checked, got %d", expected, checked)
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	tt := []struct {
+		description string
+		method      string
+		url         string
+		payload     string
+		rc          int
+	}{
+		{"list", GET, "/tasks", "", 200},
+		{"list with slash", GET, "/tasks/", "", 200},
+		{"add with slash", POST, "/tasks/", "text=another task", 204},
+		{"complete with slash", POST, "/tasks/complete/", "id=task1", 204},
+		{"unknown path", GET, "/tasks/nowhere/", "", 404},
+		{"client", GET, "/", "", 200},
+	}
+
+	for _, tst := range tt {
+		usecontext(t, onetask())
+
+		req, err := http.NewRequest(tst.method, tst.url, strings.NewReader(tst.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		trimSlash(router()).ServeHTTP(w, req)
+
+		if w.Code != tst.rc {
+			t.Errorf("%s: expected status %d, got %d", tst.description, tst.rc, w.Code)
+		}
+	}
+}
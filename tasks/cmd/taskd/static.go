@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/uber-apps/tasks"
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/golang.org/x/net/context"
+)
+
+// assetdir, when set, names a directory the browser client is served from in place of the
+// copy embedded in the binary. It is meant for use while working on the client.
+var assetdir string
+
+// asset describes a file of the browser client and where it is served.
+type asset struct {
+	path        string
+	file        string
+	contentType string
+}
+
+var assets = []asset{
+	{"/", "index.html", "text/html; charset=utf-8"},
+	{"/tasks.js", "tasks.js", "application/javascript; charset=utf-8"},
+}
+
+// clientasset creates a handler that serves a file of the browser client. Embedded files
+// may be cached for an hour, files served from assetdir must be revalidated on every use.
+// Both carry an ETag so revalidation is cheap.
+func clientasset(a asset) ContextHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+		var (
+			bs    []byte
+			err   error
+			cache = "public, max-age=3600"
+		)
+
+		if len(assetdir) > 0 {
+			bs, err = ioutil.ReadFile(filepath.Join(assetdir, a.file))
+			cache = "no-cache"
+		} else {
+			bs, err = tasks.Client.ReadFile(a.file)
+		}
+
+		if err != nil {
+			writeError(w, req, newError(errAssetUnavailable, "Cannot read client asset "+a.file))
+			return
+		}
+
+		w.Header().Set("Content-Type", a.contentType)
+		w.Header().Set("Cache-Control", cache)
+		w.Header().Set("ETag", fmt.Sprintf("\"%x\"", sha1.Sum(bs)))
+		http.ServeContent(w, req, a.file, time.Time{}, bytes.NewReader(bs))
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/golang.org/x/net/context"
+)
+
+func TestClientAssets(t *testing.T) {
+	tt := []struct {
+		description string
+		path        string
+		ct          string
+		contains    string
+	}{
+		{"index page", "/", "text/html; charset=utf-8", "<title>Tasks UBER</title>"},
+		{"client script", "/tasks.js", "application/javascript; charset=utf-8", "client side library for tasks"},
+	}
+
+	r := router()
+	for _, tst := range tt {
+		req, err := http.NewRequest(GET, tst.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: Response Code mismatch: expected %d, got %d", tst.description, http.StatusOK, w.Code)
+			continue
+		}
+
+		if ct := w.Header().Get("Content-Type"); ct != tst.ct {
+			t.Errorf("%s: Content-Type mismatch: expected %s, got %s", tst.description, tst.ct, ct)
+		}
+
+		if cc := w.Header().Get("Cache-Control"); cc != "public, max-age=3600" {
+			t.Errorf("%s: Cache-Control mismatch: got %q", tst.description, cc)
+		}
+
+		if !strings.Contains(w.Body.String(), tst.contains) {
+			t.Errorf("%s: expected body containing %q", tst.description, tst.contains)
+		}
+
+		req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotModified {
+			t.Errorf("%s: revalidation: expected %d, got %d", tst.description, http.StatusNotModified, w.Code)
+		}
+	}
+}
+
+func TestClientAssetDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "taskd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "tasks.js"), []byte("/* development copy */"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	assetdir = dir
+	defer func() { assetdir = "" }()
+
+	req, err := http.NewRequest(GET, "/tasks.js", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	router().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || w.Body.String() != "/* development copy */" {
+		t.Errorf("expected development copy, got %d %q", w.Code, w.Body.String())
+	}
+
+	if cc := w.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("Cache-Control mismatch: expected no-cache, got %q", cc)
+	}
+}
+
+// browserPage is what testdata/browser.js records the page showing.
+type browserPage struct {
+	Tasks   []string `json:"tasks"`
+	Buttons []string `json:"buttons"`
+	Alerts  []string `json:"alerts"`
+}
+
+// runBrowser runs the browser client served by a taskd with ctx through steps in node, see
+// testdata/browser.js, and returns what the page showed after loading and after each step.
+func runBrowser(t *testing.T, ctx context.Context, steps string) []browserPage {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is needed to run the browser client")
+	}
+
+	usecontext(t, ctx)
+	srv := httptest.NewServer(trimSlash(router()))
+	defer srv.Close()
+
+	out, err := exec.Command(node, filepath.Join("testdata", "browser.js"), srv.URL, steps).Output()
+	if err != nil {
+		t.Fatalf("running the browser client: %v", err)
+	}
+	var pages []browserPage
+	if err := json.Unmarshal(out, &pages); err != nil {
+		t.Fatalf("the browser client failed: %s", out)
+	}
+	return pages
+}
+
+func TestBrowserClient(t *testing.T) {
+	pages := runBrowser(t, multipletasks(), `[
+		{"click": "add", "answers": ["four"]},
+		{"click": "task1/complete"},
+		{"click": "task1/edit", "answers": ["task 2"]},
+		{"click": "search", "answers": ["four"]}
+	]`)
+
+	expected := []string{
+		"task1:task one [self complete edit move],task2:task two [self complete edit move],task3:task three [self complete edit move]",
+		"task1:task one [self complete edit move],task2:task two [self complete edit move],task3:task three [self complete edit move],task4:four [self complete edit move]",
+		"task1:task two [self complete edit move],task2:task three [self complete edit move],task3:four [self complete edit move]",
+		"task1:task 2 [self complete edit move],task2:task three [self complete edit move],task3:four [self complete edit move]",
+		"task3:four [self complete edit move]",
+	}
+	if len(pages) != len(expected) {
+		t.Fatalf("expected %d pages, got %v", len(expected), pages)
+	}
+	for i, p := range pages {
+		if tasks := strings.Join(p.Tasks, ","); tasks != expected[i] {
+			t.Errorf("page %d: expected tasks %s, got %s", i, expected[i], tasks)
+		}
+		if buttons := strings.Join(p.Buttons, " "); buttons != "collection search add metadata sync" {
+			t.Errorf("page %d: unexpected buttons %s", i, buttons)
+		}
+		if len(p.Alerts) > 0 {
+			t.Errorf("page %d: unexpected alerts %v", i, p.Alerts)
+		}
+	}
+}
+
+func TestBrowserEvents(t *testing.T) {
+	pages := runBrowser(t, multipletasks(), `[
+		{"send": "POST /tasks/move", "body": "id=task3&position=1"},
+		{"send": "POST /tasks", "body": "text=task four"},
+		{"send": "POST /tasks/edit", "body": "id=task4&text=task 4"},
+		{"send": "POST /tasks/complete", "body": "id=task2"},
+		{"click": "task1/move", "answers": ["3"]}
+	]`)
+
+	expected := []string{
+		"task one,task two,task three",
+		"task three,task one,task two",
+		"task three,task one,task two,task four",
+		"task three,task one,task two,task 4",
+		"task three,task two,task 4",
+		"task two,task 4,task three",
+	}
+	if len(pages) != len(expected) {
+		t.Fatalf("expected %d pages, got %v", len(expected), pages)
+	}
+	for i, p := range pages {
+		texts := []string{}
+		for _, task := range p.Tasks {
+			texts = append(texts, strings.TrimSuffix(task[strings.Index(task, ":")+1:], " [self complete edit move]"))
+		}
+		if strings.Join(texts, ",") != expected[i] {
+			t.Errorf("page %d: expected tasks %s, got %v", i, expected[i], p.Tasks)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Errors returned by the taskstore's mutations.
+var (
+	errNoSuchTask      = errors.New("no such task")
+	errNoSuchConflict  = errors.New("no such conflict")
+	errConflictsFull   = errors.New("too many open conflicts")
+	errNoSuchPosition  = errors.New("no such position")
+	errVersionMismatch = errors.New("version does not match")
+)
+
+// storedTask is a task as the store keeps it. Version is the version of the store at which
+// the task was last changed. TextClock is the timestamp of the change that set the text, and
+// tags holds the timestamps of the latest add and remove of each tag the task has had.
+type storedTask struct {
+	uid       string
+	text      string
+	textClock timestamp
+	tags      map[string]tagClocks
+	version   int
+}
+
+// tagClocks are the timestamps of the latest add and remove of a tag. The tag is present if
+// it was added after it was last removed.
+type tagClocks struct {
+	added   timestamp
+	removed timestamp
+}
+
+// tagList returns the task's tags in order.
+func (t *storedTask) tagList() []string {
+	tags := []string{}
+	for tag, tc := range t.tags {
+		if tc.removed.before(tc.added) {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// view returns the format neutral model of the task, which is at position in the list.
+func (t *storedTask) view(position int) task {
+	return task{id: fmt.Sprintf("task%d", position), text: t.text, uid: t.uid, clock: t.textClock.String(), tags: t.tagList()}
+}
+
+// copy returns a copy of the task that can be changed without changing t.
+func (t *storedTask) copy() *storedTask {
+	c := *t
+	c.tags = map[string]tagClocks{}
+	for tag, tc := range t.tags {
+		c.tags[tag] = tc
+	}
+	return &c
+}
+
+// taskstore holds the task list. Every change bumps the store's version and stamps the task
+// it touches with the new version, so versions identify states of the list as a whole and of
+// each task. Tasks are named by their position in the list, counting from 1. The epoch
+// tells this store apart from any other, such as the one a restarted taskd had before.
+//
+// Changes are also stamped with the store's hybrid logical clock so that they can be merged
+// with changes clients made offline. Completed tasks leave a tombstone, the timestamp of
+// their completion, behind.
+//
+// Stored tasks are never changed, a changed task replaces the old one, so lists can hand out
+// the slice of tasks instead of copying it. Shared records that they have; the slice is then
+// copied before it is next changed in place.
+type taskstore struct {
+	mu        sync.Mutex
+	version   int
+	tasks     []*storedTask
+	shared    bool
+	epoch     string
+	feed      *feed
+	clock     *clock
+	completed map[string]timestamp
+	buried    []string
+	conflicts []conflict
+	conflictn int
+}
+
+// newStore creates a store holding tasks with the given texts. Its changes are published on
+// its feed, whose buffer is the store's change log.
+func newStore(texts ...string) *taskstore {
+	s := &taskstore{epoch: randomHex(8), feed: newFeed(), clock: newClock(serverNode), completed: map[string]timestamp{}}
+	for _, text := range texts {
+		s.add(text, precondition{})
+	}
+	return s
+}
+
+// randomHex returns n random bytes, hex encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("cannot generate random id: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
+// entity returns the state of the list, for entity tags.
+func (s *taskstore) entity() entity {
+	return entity{epoch: s.epoch, version: s.version}
+}
+
+// taskEntity returns the state of the task at position, for entity tags.
+func (s *taskstore) taskEntity(position int) entity {
+	return entity{epoch: s.epoch, version: s.tasks[position-1].version, position: position}
+}
+
+// view returns the format neutral model of the task at position.
+func (s *taskstore) view(position int) task {
+	return s.tasks[position-1].view(position)
+}
+
+// position returns the position of the task with uid, or 0 if there is none.
+func (s *taskstore) position(uid string) int {
+	for i, t := range s.tasks {
+		if t.uid == uid {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// list returns a collection of the tasks that satisfy match, or all of them if match is nil,
+// without copying them. The collection's entity is the store's.
+func (s *taskstore) list(match func(text string) bool) collection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.shared = true
+	return collection{entity: s.entity(), stored: s.tasks, match: match}
+}
+
+// snapshot is like list, but copies the tasks into the collection.
+func (s *taskstore) snapshot(match func(text string) bool) collection {
+	l := s.list(match)
+
+	c := collection{entity: l.entity, tasks: []task{}}
+	l.each(func(t task) {
+		c.tasks = append(c.tasks, t)
+	})
+	return c
+}
+
+// get returns a collection holding only the task at position. The collection's entity is
+// the task.
+func (s *taskstore) get(position int) (collection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if position < 1 || position > len(s.tasks) {
+		return collection{}, errNoSuchTask
+	}
+
+	return collection{tasks: []task{s.view(position)}, entity: s.taskEntity(position)}, nil
+}
+
+// len returns the number of tasks in the store.
+func (s *taskstore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.tasks)
+}
+
+// add appends a task to the list if pre allows the store as it is.
+func (s *taskstore) add(text string, pre precondition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !pre.allows(s.entity()) {
+		return errVersionMismatch
+	}
+
+	s.appendTask(storedTask{uid: randomHex(16), text: text, textClock: s.clock.tick(), tags: map[string]tagClocks{}})
+	return nil
+}
+
+// complete removes the task at position if pre allows the store or the task as they are.
+func (s *taskstore) complete(position int, pre precondition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if position < 1 || position > len(s.tasks) {
+		return errNoSuchTask
+	}
+	if !pre.allows(s.entity(), s.taskEntity(position)) {
+		return errVersionMismatch
+	}
+
+	s.tombstone(s.tasks[position-1].uid, s.clock.tick())
+	s.remove(position)
+	return nil
+}
+
+// edit replaces the text of the task at position if pre allows the store or the task as they
+// are.
+func (s *taskstore) edit(position int, text string, pre precondition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if position < 1 || position > len(s.tasks) {
+		return errNoSuchTask
+	}
+	if !pre.allows(s.entity(), s.taskEntity(position)) {
+		return errVersionMismatch
+	}
+
+	t := s.tasks[position-1].copy()
+	t.text, t.textClock = text, s.clock.tick()
+	s.replace(position, t)
+	return nil
+}
+
+// move moves the task at position to the position to, shifting the tasks in between, if pre
+// allows the store or the task as they are.
+func (s *taskstore) move(position, to int, pre precondition) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if position < 1 || position > len(s.tasks) {
+		return errNoSuchTask
+	}
+	if !pre.allows(s.entity(), s.taskEntity(position)) {
+		return errVersionMismatch
+	}
+	if to < 1 || to > len(s.tasks) {
+		return errNoSuchPosition
+	}
+	if to == position {
+		return nil
+	}
+
+	s.own()
+	t := s.tasks[position-1].copy()
+	s.version++
+	t.version = s.version
+	if to < position {
+		copy(s.tasks[to:position], s.tasks[to-1:position-1])
+	} else {
+		copy(s.tasks[position-1:to-1], s.tasks[position:to])
+	}
+	s.tasks[to-1] = t
+	s.feed.publish(event{id: s.version, kind: "move", task: s.view(to), from: fmt.Sprintf("task%d", position)})
+	return nil
+}
+
+// own copies the slice of tasks if lists share it, so that it can be changed in place.
+// Appending needs no copy, lists never look past their own length.
+func (s *taskstore) own() {
+	if s.shared {
+		s.tasks = append(make([]*storedTask, 0, len(s.tasks)+1), s.tasks...)
+		s.shared = false
+	}
+}
+
+// appendTask adds t to the end of the list and publishes its addition.
+func (s *taskstore) appendTask(t storedTask) {
+	s.version++
+	t.version = s.version
+	s.tasks = append(s.tasks, &t)
+	s.feed.publish(event{id: s.version, kind: "add", task: s.view(len(s.tasks))})
+}
+
+// replace puts t, stamped with a new version, in the place of the task at position and
+// publishes its edit.
+func (s *taskstore) replace(position int, t *storedTask) {
+	s.own()
+	s.version++
+	t.version = s.version
+	s.tasks[position-1] = t
+	s.feed.publish(event{id: s.version, kind: "edit", task: s.view(position)})
+}
+
+// remove takes the task at position off the list and publishes its completion.
+func (s *taskstore) remove(position int) {
+	s.own()
+	s.version++
+	s.feed.publish(event{id: s.version, kind: "complete", task: s.view(position)})
+	s.tasks = append(s.tasks[:position-1], s.tasks[position:]...)
+}
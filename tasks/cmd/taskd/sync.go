@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/golang.org/x/net/context"
+	"github.com/uber-apps/tasks/uber"
+)
+
+// encodeCursor returns the sync cursor for the given version of the store with epoch.
+// Clients must treat it as opaque.
+func encodeCursor(epoch string, version int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d", epoch, version)))
+}
+
+// decodeCursor returns the epoch and version a cursor issued by encodeCursor stands for.
+func decodeCursor(cursor string) (string, int, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, false
+	}
+
+	parts := strings.SplitN(string(b), ":", 2)
+	if len(parts) != 2 {
+		return "", 0, false
+	}
+	version, err := strconv.Atoi(parts[1])
+	if err != nil || version < 0 {
+		return "", 0, false
+	}
+	return parts[0], version, true
+}
+
+// tasksync responds with the changes made to the task list since the state named by the
+// cursor parameter, in the order they were made, and a cursor for the state they bring the
+// client to. Completions are sent as tombstones. Without a cursor, or with one older than the
+// change log reaches back or issued before taskd was restarted, the whole list is sent
+// instead and the client must replace its copy.
+func tasksync(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	tasks := ctx.Value("tasks").(*taskstore)
+
+	values, ok := uber.MustParseTemplate(transitions["sync"].template()).Match(req.URL.RequestURI())
+	if !ok {
+		writeError(w, req, newError(errTemplateMismatch, "Request does not match the sync template"))
+		return
+	}
+
+	if cursor := values["cursor"]; len(cursor) > 0 {
+		epoch, version, ok := decodeCursor(cursor)
+		if !ok {
+			writeError(w, req, newError(errInvalidCursor, "Malformed sync cursor",
+				uber.FieldError{Field: "cursor", Code: fieldInvalid, Message: "The cursor must be one returned by an earlier sync"}))
+			return
+		}
+		if changes, ok := tasks.feed.since(version); ok && epoch == tasks.epoch {
+			if len(changes) > 0 {
+				version = changes[len(changes)-1].id
+			}
+			writeDoc(w, req, http.StatusOK, mkSync("incremental", encodeCursor(tasks.epoch, version), changesData(changes)))
+			return
+		}
+	}
+
+	c := tasks.snapshot(nil)
+	list := uber.NewData().ID("tasks")
+	for _, t := range c.tasks {
+		list.Append(taskData(t.id, t.text, itemlinks).Append(syncFields(t)...))
+	}
+	writeDoc(w, req, http.StatusOK, mkSync("full", encodeCursor(tasks.epoch, c.version), list))
+}
+
+// changesData creates the Uber representation of a run of changes. Adds, edits and moves
+// carry the task as it was after the change, moves also the id it had before. Completions
+// are tombstones carrying only its id and uid.
+// Ids are positions, so clients must apply the changes in order.
+func changesData(changes []event) *uber.DataBuilder {
+	d := uber.NewData().ID("changes")
+	for _, e := range changes {
+		name := "changes"
+		if e.kind == "complete" {
+			name = "tombstones"
+		}
+
+		change := uber.NewData().ID(fmt.Sprintf("change%d", e.id)).Name(name).Append(
+			uber.NewData().Name("kind").Value(e.kind),
+			uber.NewData().Name("id").Value(e.task.id))
+		if e.kind == "complete" {
+			change.Append(uber.NewData().Name("uid").Value(e.task.uid))
+		} else {
+			change.Append(uber.NewData().Name("text").Value(e.task.text)).Append(syncFields(e.task)...)
+		}
+		if e.kind == "move" {
+			change.Append(uber.NewData().Name("from").Value(e.from))
+		}
+		d.Append(change)
+	}
+	return d
+}
+
+// mkSync creates the response to a sync request of the given mode, full or incremental. It
+// links to the next sync from cursor, and to the transitions that merge changes made offline.
+func mkSync(mode, cursor string, content *uber.DataBuilder) *uber.Doc {
+	links := mergeLinks().
+		Append(uber.NewData().ID("next").Rel("next").URL(transitions["sync"].href() + "?" + url.Values{"cursor": {cursor}}.Encode()).Action(uber.ActionRead))
+
+	sync := uber.NewData().ID("sync").Append(
+		uber.NewData().Name("mode").Value(mode),
+		uber.NewData().Name("cursor").Value(cursor))
+
+	return uber.NewDoc().Data(links, sync, content).Build()
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/golang.org/x/net/context"
+	"github.com/uber-apps/tasks/uber"
+)
+
+func TestSync(t *testing.T) {
+	usecontext(t, multipletasks())
+	r := router()
+	store := taskctx.Value("tasks").(*taskstore)
+
+	sync := func(cursor string) (int, *uber.Doc) {
+		u := "/tasks/sync"
+		if len(cursor) > 0 {
+			u += "?cursor=" + cursor
+		}
+		req, err := http.NewRequest(GET, u, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		ud, err := uber.Parse(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if errs := uber.Validate(ud); len(errs) > 0 {
+			t.Errorf("sync %q: invalid document: %v", cursor, errs)
+		}
+		return w.Code, ud
+	}
+
+	value := func(ud *uber.Doc, name string) string {
+		if ds := ud.FindByName(name); len(ds) > 0 {
+			return ds[0].Value
+		}
+		return ""
+	}
+
+	rc, ud := sync("")
+	start := value(ud, "cursor")
+	if rc != 200 || value(ud, "mode") != "full" || len(ud.FindByID("tasks").Data) != 3 {
+		t.Fatalf("initial sync: expected a full sync of 3 tasks, got %d %s", rc, value(ud, "mode"))
+	}
+	if next := ud.FindByRel("next"); len(next) != 1 || next[0].URL != "/tasks/sync?cursor="+start {
+		t.Errorf("initial sync: expected a link to the next sync, got %+v", next)
+	}
+
+	store.add("task four", precondition{})
+	store.complete(1, precondition{})
+	store.edit(1, "task 2", precondition{})
+	store.move(3, 1, precondition{})
+
+	rc, ud = sync(start)
+	if rc != 200 || value(ud, "mode") != "incremental" {
+		t.Fatalf("catching up: expected an incremental sync, got %d %s", rc, value(ud, "mode"))
+	}
+	changes := []string{}
+	for _, c := range ud.FindByID("changes").Data {
+		change := c.Name
+		for _, d := range c.Data {
+			if d.Name == "kind" || d.Name == "id" || d.Name == "text" || d.Name == "from" {
+				change += " " + d.Value
+			}
+		}
+		changes = append(changes, change)
+		if uid := c.FindByName("uid"); len(uid) != 1 || len(uid[0].Value) == 0 {
+			t.Errorf("catching up: expected %s to carry the task's uid", c.ID)
+		}
+	}
+	if expected := "changes add task4 task four,tombstones complete task1,changes edit task1 task 2,changes move task1 task four task3"; strings.Join(changes, ",") != expected {
+		t.Errorf("catching up: expected %s, got %s", expected, strings.Join(changes, ","))
+	}
+
+	caughtup := value(ud, "cursor")
+	if caughtup == start {
+		t.Errorf("catching up: expected a new cursor")
+	}
+
+	tt := []struct {
+		description string
+		cursor      string
+		rc          int
+		mode        string
+		code        string
+	}{
+		{"up to date", caughtup, 200, "incremental", ""},
+		{"other store", encodeCursor("0123456789abcdef", 3), 200, "full", ""},
+		{"future version", encodeCursor(store.epoch, 99), 200, "full", ""},
+		{"malformed cursor", "not-a-cursor", 400, "", errInvalidCursor},
+	}
+
+	for _, tst := range tt {
+		rc, ud := sync(tst.cursor)
+		if rc != tst.rc {
+			t.Errorf("%s: expected status %d, got %d", tst.description, tst.rc, rc)
+			continue
+		}
+		if len(tst.code) > 0 {
+			if e := uber.ErrorFromDoc(ud); e == nil || e.Code != tst.code {
+				t.Errorf("%s: expected error %s, got %v", tst.description, tst.code, e)
+			}
+			continue
+		}
+		if mode := value(ud, "mode"); mode != tst.mode {
+			t.Errorf("%s: expected a %s sync, got %s", tst.description, tst.mode, mode)
+		}
+		if cursor := value(ud, "cursor"); cursor != caughtup {
+			t.Errorf("%s: expected cursor %s, got %s", tst.description, caughtup, cursor)
+		}
+		if tst.mode == "incremental" && len(ud.FindByID("changes").Data) != 0 {
+			t.Errorf("%s: expected no changes", tst.description)
+		}
+	}
+}
+
+func TestSyncCompactedHistory(t *testing.T) {
+	store := newStore()
+	for i := 0; i < eventBufferSize+1; i++ {
+		store.add(fmt.Sprintf("task %d", i), precondition{})
+	}
+	usecontext(t, context.WithValue(notasks(), "tasks", store))
+
+	tt := []struct {
+		description string
+		version     int
+		mode        string
+	}{
+		{"history compacted", 0, "full"},
+		{"oldest change kept", 1, "incremental"},
+	}
+
+	for _, tst := range tt {
+		req, err := http.NewRequest(GET, "/tasks/sync?cursor="+encodeCursor(store.epoch, tst.version), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		router().ServeHTTP(w, req)
+
+		ud, err := uber.Parse(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := ud.FindByName("mode"); len(mode) != 1 || mode[0].Value != tst.mode {
+			t.Errorf("%s: expected a %s sync, got %+v", tst.description, tst.mode, mode)
+		}
+	}
+}
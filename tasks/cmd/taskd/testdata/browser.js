@@ -0,0 +1,202 @@
+// browser.js runs the tasks.js served by a taskd against that taskd, with just enough of a
+// browser for the client: a small DOM, XMLHttpRequest, EventSource, prompt and alert.
+//
+//	node browser.js <base url> <steps>
+//
+// Steps is a JSON array. Each step clicks a control, {"click": "<button id>"} or
+// {"click": "<task id>/<rel>"}, answering the prompts it opens with "answers", or sends a
+// request as another client would, {"send": "POST /tasks/move", "body": "id=task1"}. After
+// the page has loaded and after every step the harness waits for the page to show the list
+// again and records what it shows. The records are written to stdout as a JSON array.
+
+const vm = require('vm');
+
+const [base, steps] = [process.argv[2], JSON.parse(process.argv[3] || '[]')];
+
+let pending = 0, renders = 0;
+const answers = [], alerts = [], streams = [];
+
+class Element {
+  constructor(tagName) {
+    this.tagName = tagName;
+    this.children = [];
+    this.attributes = {};
+    this.text = '';
+  }
+  setAttribute(name, value) { this.attributes[name] = String(value); }
+  getAttribute(name) { return name in this.attributes ? this.attributes[name] : null; }
+  appendChild(child) { this.children.push(child); return child; }
+  set innerHTML(html) {
+    this.children = [];
+    this.text = html;
+    if (this.id === 'data') {
+      renders++;
+    }
+  }
+  set textContent(text) { this.text = text; }
+  get textContent() { return this.text + this.children.map((c) => c.textContent).join(''); }
+  find(match) {
+    if (match(this)) {
+      return this;
+    }
+    for (const c of this.children) {
+      const found = c.find(match);
+      if (found) {
+        return found;
+      }
+    }
+    return null;
+  }
+}
+for (const name of ['id', 'href', 'rel', 'title', 'type', 'value', 'className']) {
+  Object.defineProperty(Element.prototype, name, {
+    get() { return this.getAttribute(name) || ''; },
+    set(value) { this.setAttribute(name, value); },
+  });
+}
+
+const body = new Element('body');
+body.appendChild(new Element('ul')).id = 'data';
+body.appendChild(new Element('div')).id = 'actions';
+
+class XMLHttpRequest {
+  open(method, url) {
+    this.method = method.toUpperCase();
+    this.url = new URL(url, base);
+    this.headers = {};
+  }
+  setRequestHeader(name, value) { this.headers[name] = value; }
+  send(payload) {
+    pending++;
+    fetch(this.url, {method: this.method, headers: this.headers, body: payload === null ? undefined : payload})
+      .then(async (res) => {
+        this.status = res.status;
+        this.statusText = res.statusText;
+        this.responseText = await res.text();
+      }, (err) => {
+        this.status = 0;
+        this.statusText = err.message;
+      })
+      .then(() => {
+        this.readyState = 4;
+        this.onreadystatechange();
+        pending--;
+      });
+  }
+}
+
+class EventSource {
+  constructor(url) {
+    this.listeners = {};
+    this.readyState = 0;
+    this.abort = new AbortController();
+    streams.push(this);
+    this.read(new URL(url, base)).catch(() => {});
+  }
+  addEventListener(kind, f) { (this.listeners[kind] = this.listeners[kind] || []).push(f); }
+  close() { this.abort.abort(); }
+  async read(url) {
+    const res = await fetch(url, {headers: {Accept: 'text/event-stream'}, signal: this.abort.signal});
+    this.readyState = 1;
+    const decoder = new TextDecoder();
+    let buffered = '';
+    for await (const chunk of res.body) {
+      buffered += decoder.decode(chunk, {stream: true});
+      let end;
+      while ((end = buffered.indexOf('\n\n')) >= 0) {
+        const e = {type: 'message', data: [], lastEventId: ''};
+        for (const line of buffered.slice(0, end).split('\n')) {
+          const [field, value] = [line.slice(0, line.indexOf(':')), line.slice(line.indexOf(':') + 1).trim()];
+          if (field === 'event') e.type = value;
+          if (field === 'data') e.data.push(value);
+          if (field === 'id') e.lastEventId = value;
+        }
+        buffered = buffered.slice(end + 2);
+        e.data = e.data.join('\n');
+        (this.listeners[e.type] || []).forEach((f) => f(e));
+      }
+    }
+  }
+}
+
+Object.assign(globalThis, {
+  window: globalThis,
+  document: {
+    createElement: (tagName) => new Element(tagName),
+    getElementById: (id) => body.find((e) => e.id === id),
+  },
+  XMLHttpRequest,
+  EventSource,
+  prompt: () => (answers.length > 0 ? answers.shift() : null),
+  alert: (msg) => alerts.push(msg),
+});
+
+// settled waits until the page has shown the list more than seen times, is watching the
+// change feed and has been quiet for a while, so that reloads the feed prompts are done too.
+async function settled(seen) {
+  const deadline = Date.now() + 5000;
+  let quiet = 0, last = renders;
+  while (renders <= seen || pending > 0 || streams.some((s) => s.readyState !== 1) || quiet < 5) {
+    if (Date.now() > deadline) {
+      throw new Error('timed out waiting for the page to show the list');
+    }
+    await new Promise((resolve) => setTimeout(resolve, 10));
+    quiet = (pending === 0 && renders === last ? quiet + 1 : 0);
+    last = renders;
+  }
+}
+
+// page records the tasks the page lists, with the rels of their links, and its buttons.
+function page() {
+  const list = document.getElementById('data'), actions = document.getElementById('actions');
+  return {
+    tasks: list.children.map((li) => {
+      const rels = li.children[0].children.filter((c) => c.tagName === 'a').map((a) => a.rel);
+      return li.id + ':' + li.textContent.replace(/X/g, '') + ' [' + rels.join(' ') + ']';
+    }),
+    buttons: actions.children.map((b) => b.value),
+    alerts: alerts.splice(0),
+  };
+}
+
+async function run() {
+  const script = await (await fetch(new URL('/tasks.js', base))).text();
+  vm.runInThisContext(script, {filename: 'tasks.js'});
+
+  const records = [];
+  let seen = renders;
+  window.onload();
+  await settled(seen);
+  records.push(page());
+
+  for (const step of steps) {
+    seen = renders;
+    answers.push(...(step.answers || []));
+    if (step.click) {
+      const [id, rel] = step.click.split('/');
+      let control = document.getElementById(id);
+      if (control && rel) {
+        control = control.find((e) => e.tagName === 'a' && e.rel === rel);
+      }
+      if (!control) {
+        throw new Error('no control ' + step.click);
+      }
+      control.onclick();
+    } else {
+      const [method, path] = step.send.split(' ');
+      await fetch(new URL(path, base), {method, headers: {'Content-Type': 'application/x-www-form-urlencoded'}, body: step.body});
+    }
+    await settled(seen);
+    records.push(page());
+  }
+  return records;
+}
+
+run().then((records) => {
+  console.log(JSON.stringify(records));
+}, (err) => {
+  console.log(JSON.stringify({error: err.message, page: page()}));
+}).finally(() => {
+  streams.forEach((s) => s.close());
+  process.exit(0);
+});
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/golang.org/x/net/context"
+	"github.com/uber-apps/tasks/uber"
+)
+
+// maxExpandDepth bounds how deeply transcluded resources may themselves be expanded.
+const maxExpandDepth = 3
+
+// embeddable maps the ids of the transitions whose targets may be transcluded to the
+// handlers that serve them. Only resources of the list as a whole are embeddable; links
+// of the items, which lead back to the items themselves, are never expanded.
+var embeddable = map[string]ContextHandlerFunc{}
+
+func init() {
+	embeddable["list"] = tasklist
+	embeddable["metadata"] = taskmetadata
+}
+
+// expansion is the set of link relations a client asked to have transcluded, via
+// ?expand=rel,rel, and how many levels deep, via ?depth=n, to keep expanding them.
+type expansion struct {
+	rels  map[string]bool
+	depth int
+}
+
+// requestedExpansion returns the expansion asked for by the request's query parameters. The
+// depth defaults to 1 and is capped at maxExpandDepth.
+func requestedExpansion(req *http.Request) expansion {
+	q := req.URL.Query()
+
+	e := expansion{rels: map[string]bool{}, depth: 1}
+	for _, rel := range strings.Split(q.Get("expand"), ",") {
+		if rel = strings.TrimSpace(rel); len(rel) > 0 {
+			e.rels[rel] = true
+		}
+	}
+
+	if d, err := strconv.Atoi(q.Get("depth")); err == nil {
+		e.depth = d
+	}
+	if e.depth > maxExpandDepth {
+		e.depth = maxExpandDepth
+	}
+
+	return e
+}
+
+// none reports whether the expansion asks for nothing to be transcluded.
+func (e expansion) none() bool {
+	return len(e.rels) == 0 || e.depth <= 0
+}
+
+// query encodes the expansion for a request made one level further down.
+func (e expansion) query() string {
+	rels := []string{}
+	for rel := range e.rels {
+		rels = append(rels, rel)
+	}
+	return url.Values{"expand": {strings.Join(rels, ",")}, "depth": {strconv.Itoa(e.depth - 1)}}.Encode()
+}
+
+// transclude embeds, in place, the content of every embeddable link in the document whose
+// rel was asked for. Embedded elements keep their url and are marked with transclude so
+// clients can tell the content was fetched for them. Ids within the embedded content are
+// prefixed with the id of the link they were embedded in to keep the document's ids unique.
+// Links to the requested resource itself, such as the list's collection link, are left
+// alone, so a document never embeds a copy of itself.
+func transclude(ctx context.Context, req *http.Request, ud *uber.Doc) {
+	e := requestedExpansion(req)
+	if e.none() {
+		return
+	}
+
+	ud.Walk(func(path string, d *uber.Data) error {
+		if len(d.URL) == 0 || d.URL == req.URL.Path || d.Template || d.Transclude || uber.Method(d.Action) != "GET" {
+			return nil
+		}
+
+		requested := false
+		for _, rel := range d.Rel {
+			requested = requested || e.rels[rel]
+		}
+		if !requested {
+			return nil
+		}
+
+		for id, hfn := range embeddable {
+			if transitions[id].href() != d.URL {
+				continue
+			}
+
+			if embedded, ok := fetch(ctx, hfn, d.URL+"?"+e.query()); ok {
+				prefix := d.ID
+				if len(prefix) == 0 {
+					prefix = d.Rel[0]
+				}
+				embedded.Walk(func(_ string, ed *uber.Data) error {
+					if len(ed.ID) > 0 {
+						ed.ID = prefix + "." + ed.ID
+					}
+					return nil
+				})
+
+				d.Transclude = true
+				d.Data = append(d.Data, embedded.Uber.Data...)
+			}
+			return uber.SkipData
+		}
+
+		return nil
+	})
+}
+
+// fetch performs an internal GET of target with hfn and decodes the Uber document it
+// responds with.
+func fetch(ctx context.Context, hfn ContextHandlerFunc, target string) (*uber.Doc, bool) {
+	req, err := http.NewRequest("GET", target, nil)
+	if err != nil {
+		return nil, false
+	}
+	req.Header.Set("Accept", uberType)
+
+	w := &captureWriter{header: http.Header{}, code: http.StatusOK}
+	hfn(ctx, w, req)
+	if w.code != http.StatusOK {
+		return nil, false
+	}
+
+	ud, err := uber.Parse(&w.body)
+	if err != nil {
+		return nil, false
+	}
+	return ud, true
+}
+
+// captureWriter is an http.ResponseWriter that holds on to the response.
+type captureWriter struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (cw *captureWriter) Header() http.Header         { return cw.header }
+func (cw *captureWriter) WriteHeader(code int)        { cw.code = code }
+func (cw *captureWriter) Write(b []byte) (int, error) { return cw.body.Write(b) }
+
+// taskmetadata responds with information about the task list as a whole.
+func taskmetadata(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	tasks := ctx.Value("tasks").(*taskstore)
+
+	resp := uber.NewDoc().Data(uber.NewData().Name("metadata").Append(
+		uber.NewData().Name("count").Value(strconv.Itoa(tasks.len())))).Build()
+
+	writeDoc(w, req, http.StatusOK, resp)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/uber-apps/tasks/uber"
+)
+
+func TestTransclude(t *testing.T) {
+	tt := []struct {
+		description string
+		hfn         ContextHandlerFunc
+		req         string
+		transcluded int
+		count       string
+	}{
+		{"no expansion", tasklist, "/tasks", 0, ""},
+		{"expand metadata", tasklist, "/tasks?expand=metadata", 1, "3"},
+		{"expand unknown rel", tasklist, "/tasks?expand=nonesuch", 0, ""},
+		{"list does not embed itself", tasklist, "/tasks?expand=collection&depth=3", 0, ""},
+		{"expand collection", tasksearch, "/tasks/search?text=task&expand=collection", 1, ""},
+		{"embedded list does not embed itself", tasksearch, "/tasks/search?text=task&expand=collection&depth=3", 1, ""},
+		{"embedded list expands its metadata", tasksearch, "/tasks/search?text=task&expand=collection,metadata&depth=100", 3, "3"},
+		{"expansion disabled", tasklist, "/tasks?expand=metadata&depth=0", 0, ""},
+	}
+
+	for _, tst := range tt {
+		req, err := http.NewRequest(GET, tst.req, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		w := httptest.NewRecorder()
+		tst.hfn(multipletasks(), w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: Response Code mismatch: expected %d, got %d", tst.description, http.StatusOK, w.Code)
+			continue
+		}
+
+		var ud uber.Doc
+		if err := json.Unmarshal(w.Body.Bytes(), &ud); err != nil {
+			t.Errorf("%s: cannot decode response: %v", tst.description, err)
+			continue
+		}
+
+		if errs := uber.Validate(&ud); len(errs) > 0 {
+			t.Errorf("%s: response is not valid Uber: %v", tst.description, errs)
+		}
+
+		transcluded, count := 0, ""
+		ud.Walk(func(_ string, d *uber.Data) error {
+			if d.Transclude {
+				transcluded++
+			}
+			if d.Name == "count" && len(count) == 0 {
+				count = d.Value
+			}
+			return nil
+		})
+
+		if transcluded != tst.transcluded {
+			t.Errorf("%s: expected %d transcluded elements, got %d", tst.description, tst.transcluded, transcluded)
+		}
+
+		if count != tst.count {
+			t.Errorf("%s: expected count %q, got %q", tst.description, tst.count, count)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/uber-apps/tasks/uber"
+)
+
+// validate implements the validate command. It checks each named file against the Uber 1.0
+// specification, reporting violations to w, and returns the process exit status: 0 if every
+// document is valid, 1 if any is not and 2 if a file cannot be read or parsed.
+func validate(args []string, w io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintln(w, "usage: taskd validate file ...")
+		return 2
+	}
+
+	status := 0
+	for _, fn := range args {
+		f, err := os.Open(fn)
+		if err != nil {
+			fmt.Fprintf(w, "%s: %v\n", fn, err)
+			status = 2
+			continue
+		}
+
+		ud, err := uber.Parse(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintf(w, "%s: %v\n", fn, err)
+			status = 2
+			continue
+		}
+
+		errs := uber.Validate(ud)
+		for _, e := range errs {
+			fmt.Fprintf(w, "%s: %v\n", fn, e)
+		}
+
+		if len(errs) > 0 {
+			if status == 0 {
+				status = 1
+			}
+			continue
+		}
+
+		fmt.Fprintf(w, "%s: valid Uber %s document\n", fn, ud.Uber.Version)
+	}
+
+	return status
+}
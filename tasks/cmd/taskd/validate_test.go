@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/uber-apps/tasks/cmd/taskd/data"
+)
+
+func TestValidate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "taskd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"multiple.json": data.Multipletasks,
+		"invalid.json":  `{"uber":{"version":"1.0","data":[{"url":"/tasks","action":"get"}]}}`,
+		"garbage.json":  `<uber/>`,
+	}
+	for fn, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, fn), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tt := []struct {
+		description string
+		files       []string
+		status      int
+		output      string
+	}{
+		{"valid document", []string{"multiple.json"}, 0, "valid Uber 1.0 document"},
+		{"invalid document", []string{"multiple.json", "invalid.json"}, 1, `uber.data[0]: invalid action "get"`},
+		{"unparseable document", []string{"invalid.json", "garbage.json"}, 2, "cannot parse document"},
+		{"missing document", []string{"missing.json"}, 2, "no such file"},
+		{"no arguments", []string{}, 2, "usage"},
+	}
+
+	for _, tst := range tt {
+		args := []string{}
+		for _, fn := range tst.files {
+			args = append(args, filepath.Join(dir, fn))
+		}
+
+		w := bytes.NewBuffer([]byte{})
+		if status := validate(args, w); status != tst.status {
+			t.Errorf("%s: Exit status mismatch: expected %d, got %d", tst.description, tst.status, status)
+		}
+
+		if !strings.Contains(w.String(), tst.output) {
+			t.Errorf("%s: expected output containing %q, got %q", tst.description, tst.output, w.String())
+		}
+	}
+}
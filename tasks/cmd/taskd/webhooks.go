@@ -0,0 +1,462 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/golang.org/x/net/context"
+	"github.com/uber-apps/tasks/uber"
+)
+
+// maxDeadLetters is how many failed deliveries the dead letter list keeps.
+const maxDeadLetters = 100
+
+// maxQueuedDeliveries is how many deliveries a subscription can have waiting. Deliveries
+// beyond that go straight to the dead letter list.
+var maxQueuedDeliveries = 100
+
+// webhookEvents are the kinds of change a webhook can subscribe to. Subscribers are also sent
+// reset events, which say that changes were missed.
+var webhookEvents = []string{"add", "complete", "edit", "move"}
+
+// lookupIP resolves the host of a webhook URL when it is subscribed.
+var lookupIP = net.LookupIP
+
+// sharedAddressSpace is the range carrier-grade NAT uses, RFC 6598. Like the private ranges
+// it only reaches hosts inside some network.
+var _, sharedAddressSpace, _ = net.ParseCIDR("100.64.0.0/10")
+
+// subscription is a webhook: a URL that is posted the changes of the kinds listed in events.
+// Deliveries are signed with secret. They wait in queue for the subscription's worker, which
+// makes them one at a time, in order.
+type subscription struct {
+	id     string
+	url    string
+	secret string
+	events []string
+	queue  chan delivery
+}
+
+// delivery is an event waiting to be delivered to a subscription.
+type delivery struct {
+	id    string
+	event event
+}
+
+// wants reports whether the subscription is sent events of the given kind.
+func (s subscription) wants(kind string) bool {
+	return kind == "reset" || contains(s.events, kind)
+}
+
+// deadLetter records a delivery that failed after every attempt.
+type deadLetter struct {
+	id       string
+	hook     subscription
+	event    event
+	attempts int
+	reason   string
+	failed   time.Time
+}
+
+// webhooks holds the webhook subscriptions and delivers the changes published on a feed to
+// them. A delivery that fails is retried, waiting backoff, then twice as long, and so on, up
+// to attempts times in all, before it is put on the dead letter list. Deliveries only connect
+// to addresses dialable allows.
+type webhooks struct {
+	mu         sync.Mutex
+	next       int
+	deliveries int
+	hooks      []subscription
+	dead       []deadLetter
+
+	client   *http.Client
+	dialable func(net.IP) bool
+	attempts int
+	backoff  time.Duration
+	inflight sync.WaitGroup
+}
+
+func newWebhooks() *webhooks {
+	hooks := &webhooks{dialable: publicIP, attempts: 6, backoff: time.Second}
+
+	// The address is checked as it is dialled, after it has been resolved, so that a name
+	// that resolved to a public address when it was subscribed, or a redirect, can't be used
+	// to reach a private one.
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: func(network, address string, c syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || !hooks.dialable(ip) {
+			return fmt.Errorf("refusing to deliver to %s, which is not a public address", host)
+		}
+		return nil
+	}}
+	hooks.client = &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 10 * time.Second},
+	}
+	return hooks
+}
+
+// subscribe adds a subscription, starts its worker and returns it.
+func (hooks *webhooks) subscribe(u, secret string, events []string) subscription {
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+
+	hooks.next++
+	s := subscription{id: fmt.Sprintf("hook%d", hooks.next), url: u, secret: secret, events: events,
+		queue: make(chan delivery, maxQueuedDeliveries)}
+	hooks.hooks = append(hooks.hooks, s)
+	go hooks.work(s)
+	return s
+}
+
+// unsubscribe removes the subscription with id. Deliveries already queued are completed.
+func (hooks *webhooks) unsubscribe(id string) bool {
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+
+	for i, s := range hooks.hooks {
+		if s.id == id {
+			hooks.hooks = append(hooks.hooks[:i], hooks.hooks[i+1:]...)
+			close(s.queue)
+			return true
+		}
+	}
+	return false
+}
+
+// subscriptions returns the current subscriptions, oldest first.
+func (hooks *webhooks) subscriptions() []subscription {
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+
+	return append([]subscription{}, hooks.hooks...)
+}
+
+// deadLetters returns the failed deliveries, oldest first.
+func (hooks *webhooks) deadLetters() []deadLetter {
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+
+	return append([]deadLetter{}, hooks.dead...)
+}
+
+// dispatch delivers every event published on f to the subscriptions that want it, until stop
+// is closed. If it falls behind the feed it resumes from the feed's buffer.
+func (hooks *webhooks) dispatch(f *feed, stop <-chan struct{}) {
+	last, resuming := 0, false
+	for {
+		missed, ch := f.watch(last, resuming)
+		for _, e := range missed {
+			hooks.publish(e)
+			last = e.id
+		}
+
+	watching:
+		for {
+			select {
+			case e, open := <-ch:
+				if !open {
+					break watching
+				}
+				hooks.publish(e)
+				last = e.id
+			case <-stop:
+				f.unwatch(ch)
+				return
+			}
+		}
+		resuming = true
+	}
+}
+
+// publish queues a delivery of e for each subscription that wants it. Deliveries that find
+// the queue full are put on the dead letter list.
+func (hooks *webhooks) publish(e event) {
+	hooks.mu.Lock()
+	overflow := []deadLetter{}
+	for _, s := range hooks.hooks {
+		if !s.wants(e.kind) {
+			continue
+		}
+
+		hooks.deliveries++
+		d := delivery{id: fmt.Sprintf("delivery%d", hooks.deliveries), event: e}
+		hooks.inflight.Add(1)
+		select {
+		case s.queue <- d:
+		default:
+			hooks.inflight.Done()
+			overflow = append(overflow, deadLetter{id: d.id, hook: s, event: e, reason: "too many deliveries waiting"})
+		}
+	}
+	hooks.mu.Unlock()
+
+	for _, d := range overflow {
+		hooks.bury(d)
+	}
+}
+
+// work makes the deliveries queued for s, in order, until s is unsubscribed.
+func (hooks *webhooks) work(s subscription) {
+	for d := range s.queue {
+		hooks.deliver(d.id, s, d.event)
+	}
+}
+
+// deliver posts e to the subscription's URL until it is accepted with a 2xx response or every
+// attempt has failed.
+func (hooks *webhooks) deliver(id string, s subscription, e event) {
+	defer hooks.inflight.Done()
+
+	body, err := json.Marshal(e.doc())
+	if err != nil {
+		hooks.bury(deadLetter{id: id, hook: s, event: e, reason: err.Error()})
+		return
+	}
+
+	reason, wait := "", hooks.backoff
+	for attempt := 1; attempt <= hooks.attempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(wait)
+			wait *= 2
+		}
+
+		if reason = hooks.post(id, s, e, body); len(reason) == 0 {
+			return
+		}
+	}
+
+	hooks.bury(deadLetter{id: id, hook: s, event: e, attempts: hooks.attempts, reason: reason})
+}
+
+// post makes one attempt at a delivery. It returns why the attempt failed, or the empty
+// string if it succeeded.
+func (hooks *webhooks) post(id string, s subscription, e event, body []byte) string {
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return err.Error()
+	}
+	req.Header.Set("Content-Type", uberType)
+	req.Header.Set("Taskd-Event", e.kind)
+	req.Header.Set("Taskd-Event-ID", strconv.Itoa(e.id))
+	req.Header.Set("Taskd-Delivery", id)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Taskd-Timestamp", timestamp)
+	req.Header.Set("Taskd-Signature", signature(s.secret, timestamp, body))
+
+	resp, err := hooks.client.Do(req)
+	if err != nil {
+		return err.Error()
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.Status
+	}
+	return ""
+}
+
+// bury puts a failed delivery on the dead letter list, dropping the oldest if it is full.
+func (hooks *webhooks) bury(d deadLetter) {
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+
+	d.failed = time.Now()
+	hooks.dead = append(hooks.dead, d)
+	if len(hooks.dead) > maxDeadLetters {
+		hooks.dead = hooks.dead[len(hooks.dead)-maxDeadLetters:]
+	}
+}
+
+// signature returns the value of the Taskd-Signature header for a delivery of body sent at
+// timestamp, signed with secret: the hex encoded HMAC-SHA256 of the timestamp, a dot and the
+// body, prefixed with sha256=. Signing the timestamp lets receivers refuse old deliveries
+// that are sent again.
+func signature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// publicIP reports whether ip is an address webhooks may be delivered to: not loopback,
+// link-local, private, shared, unspecified or multicast.
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsPrivate() ||
+		sharedAddressSpace.Contains(ip) || ip.IsUnspecified() || ip.IsMulticast())
+}
+
+// publicHost returns an error unless host is, or resolves only to, public addresses.
+func publicHost(host string) error {
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		var err error
+		if ips, err = lookupIP(host); err != nil {
+			return err
+		}
+	}
+	for _, ip := range ips {
+		if !publicIP(ip) {
+			return fmt.Errorf("%s is not a public address", ip)
+		}
+	}
+	return nil
+}
+
+// subscribeCommand is the decoded body of a subscribe request.
+type subscribeCommand struct {
+	url    string
+	secret string
+	events []string
+}
+
+// decodeSubscribe decodes and validates the body of a subscribe request. Without events the
+// subscription is sent every kind of change.
+func decodeSubscribe(req *http.Request) (subscribeCommand, *uber.Error) {
+	args, e := decodeArgs(req, "url", "secret", "events")
+	if e != nil {
+		return subscribeCommand{}, e
+	}
+
+	const message = "Invalid subscribe body"
+	cmd := subscribeCommand{url: strings.TrimSpace(args["url"]), secret: args["secret"], events: webhookEvents}
+
+	if len(cmd.url) == 0 {
+		return cmd, newError(errInvalidBody, message,
+			uber.FieldError{Field: "url", Code: fieldRequired, Message: "The URL to deliver to is required"})
+	}
+	u, err := url.Parse(cmd.url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Hostname()) == 0 {
+		return cmd, newError(errInvalidBody, message,
+			uber.FieldError{Field: "url", Code: fieldInvalid, Message: fmt.Sprintf("%q is not an absolute http or https URL", cmd.url)})
+	}
+	if err := publicHost(u.Hostname()); err != nil {
+		return cmd, newError(errInvalidBody, message,
+			uber.FieldError{Field: "url", Code: fieldInvalid, Message: fmt.Sprintf("%q can't be delivered to: %v", cmd.url, err)})
+	}
+
+	if len(cmd.secret) == 0 {
+		return cmd, newError(errInvalidBody, message,
+			uber.FieldError{Field: "secret", Code: fieldRequired, Message: "The secret deliveries are signed with is required"})
+	}
+
+	if events := strings.Fields(strings.Replace(args["events"], ",", " ", -1)); len(events) > 0 {
+		for _, kind := range events {
+			if !contains(webhookEvents, kind) {
+				return cmd, newError(errInvalidBody, message,
+					uber.FieldError{Field: "events", Code: fieldInvalid, Message: fmt.Sprintf("%q is not one of %s", kind, strings.Join(webhookEvents, ", "))})
+			}
+		}
+		cmd.events = events
+	}
+
+	return cmd, nil
+}
+
+// webhooklist responds with the webhook subscriptions.
+func webhooklist(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	hooks := ctx.Value("webhooks").(*webhooks)
+
+	writeDoc(w, req, http.StatusOK, hooks.doc())
+}
+
+// webhooksubscribe adds a webhook subscription. It expects a body containing
+// url={url}&secret={secret}&events={events} and responds with the subscriptions, including
+// the new one.
+func webhooksubscribe(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	cmd, e := decodeSubscribe(req)
+	if e != nil {
+		writeError(w, req, e)
+		return
+	}
+
+	hooks := ctx.Value("webhooks").(*webhooks)
+	hooks.subscribe(cmd.url, cmd.secret, cmd.events)
+
+	writeDoc(w, req, http.StatusCreated, hooks.doc())
+}
+
+// webhookunsubscribe removes a webhook subscription. It expects a body containing id={id}
+// where {id} is the id of the subscription.
+func webhookunsubscribe(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	args, e := decodeArgs(req, "id")
+	if e != nil {
+		writeError(w, req, e)
+		return
+	}
+
+	id := strings.TrimSpace(args["id"])
+	if len(id) == 0 {
+		writeError(w, req, newError(errInvalidBody, "Invalid unsubscribe body",
+			uber.FieldError{Field: "id", Code: fieldRequired, Message: "The id of the webhook is required"}))
+		return
+	}
+
+	hooks := ctx.Value("webhooks").(*webhooks)
+	if !hooks.unsubscribe(id) {
+		writeError(w, req, newError(errWebhookNotFound, "No such webhook"))
+		return
+	}
+
+	writeDoc(w, req, http.StatusOK, hooks.doc())
+}
+
+// webhookdeadletters responds with the deliveries that failed after every attempt.
+func webhookdeadletters(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+	hooks := ctx.Value("webhooks").(*webhooks)
+
+	links := uber.NewData().ID("links")
+	for _, id := range webhooklinks {
+		links.Append(transitions[id].link())
+	}
+
+	dead := uber.NewData().ID("deliveries")
+	for _, d := range hooks.deadLetters() {
+		dead.Append(uber.NewData().ID(d.id).Name("deliveries").Append(
+			uber.NewData().Name("webhook").Value(d.hook.id),
+			uber.NewData().Name("url").Value(d.hook.url),
+			uber.NewData().Name("event").Value(d.event.kind),
+			uber.NewData().Name("eventid").Value(strconv.Itoa(d.event.id)),
+			uber.NewData().Name("attempts").Value(strconv.Itoa(d.attempts)),
+			uber.NewData().Name("reason").Value(d.reason),
+			uber.NewData().Name("failed").Value(d.failed.UTC().Format(time.RFC3339))))
+	}
+
+	writeDoc(w, req, http.StatusOK, uber.NewDoc().Data(links, dead).Build())
+}
+
+// doc creates the Uber document listing the subscriptions. Secrets are never shown.
+func (hooks *webhooks) doc() *uber.Doc {
+	links := uber.NewData().ID("links")
+	for _, id := range webhooklinks {
+		links.Append(transitions[id].link())
+	}
+
+	subs := uber.NewData().ID("subscriptions")
+	for _, s := range hooks.subscriptions() {
+		d := uber.NewData().ID(s.id).Rel("item").Name("subscriptions")
+		for _, id := range subscriptionlinks {
+			d.Append(transitions[id].link("id", s.id))
+		}
+		subs.Append(d.Append(
+			uber.NewData().Name("url").Value(s.url),
+			uber.NewData().Name("events").Value(strings.Join(s.events, " "))))
+	}
+
+	return uber.NewDoc().Data(links, subs).Build()
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/uber-apps/tasks/cmd/taskd/Godeps/_workspace/src/golang.org/x/net/context"
+	"github.com/uber-apps/tasks/uber"
+)
+
+// receiver is a webhook endpoint that records the deliveries it is sent. It fails the first
+// failures requests with 503 Service Unavailable.
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := ioutil.ReadAll(req.Body)
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	rc.requests = append(rc.requests, req)
+	rc.bodies = append(rc.bodies, body)
+	if rc.failures > 0 {
+		rc.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// testWebhooks returns webhooks that retry quickly and, so that they can deliver to test
+// servers, connect to any address.
+func testWebhooks() *webhooks {
+	hooks := newWebhooks()
+	hooks.attempts, hooks.backoff = 3, time.Millisecond
+	hooks.dialable = func(net.IP) bool { return true }
+	return hooks
+}
+
+// uselookup makes the hosts of webhook URLs resolve to the addresses in hosts for the rest
+// of the test. Other hosts don't resolve.
+func uselookup(t *testing.T, hosts map[string][]string) {
+	saved := lookupIP
+	lookupIP = func(host string) ([]net.IP, error) {
+		if _, ok := hosts[host]; !ok {
+			return nil, fmt.Errorf("no such host %s", host)
+		}
+		ips := []net.IP{}
+		for _, addr := range hosts[host] {
+			ips = append(ips, net.ParseIP(addr))
+		}
+		return ips, nil
+	}
+	t.Cleanup(func() { lookupIP = saved })
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	tt := []struct {
+		description string
+		failures    int
+		requests    int
+		dead        bool
+	}{
+		{"accepted at once", 0, 1, false},
+		{"accepted on retry", 2, 3, false},
+		{"never accepted", 3, 3, true},
+	}
+
+	for _, tst := range tt {
+		rc := &receiver{failures: tst.failures}
+		srv := httptest.NewServer(rc)
+
+		hooks := testWebhooks()
+		hook := hooks.subscribe(srv.URL, "s3cret", []string{"complete"})
+		hooks.publish(event{id: 1, kind: "add", task: task{id: "task1", text: "task one"}})
+		hooks.publish(event{id: 2, kind: "complete", task: task{id: "task1", text: "task one"}})
+		hooks.inflight.Wait()
+		srv.Close()
+
+		if len(rc.requests) != tst.requests {
+			t.Errorf("%s: expected %d requests, got %d", tst.description, tst.requests, len(rc.requests))
+			continue
+		}
+
+		req, body := rc.requests[0], rc.bodies[0]
+		if kind := req.Header.Get("Taskd-Event"); kind != "complete" {
+			t.Errorf("%s: expected a complete event, got %q", tst.description, kind)
+		}
+		ts := req.Header.Get("Taskd-Timestamp")
+		if sent, err := strconv.ParseInt(ts, 10, 64); err != nil || time.Since(time.Unix(sent, 0)) > time.Minute {
+			t.Errorf("%s: expected the time of the delivery, got timestamp %q", tst.description, ts)
+		}
+		if sig := req.Header.Get("Taskd-Signature"); sig != signature("s3cret", ts, body) {
+			t.Errorf("%s: signature %q does not match the timestamp and body", tst.description, sig)
+		}
+		if sig := req.Header.Get("Taskd-Signature"); sig == signature("s3cret", "0", body) {
+			t.Errorf("%s: signature %q does not cover the timestamp", tst.description, sig)
+		}
+		if ud, err := uber.Parse(strings.NewReader(string(body))); err != nil || ud.FindByID("task1") == nil {
+			t.Errorf("%s: expected the completed task, got %s", tst.description, body)
+		}
+
+		dead := hooks.deadLetters()
+		if (len(dead) > 0) != tst.dead {
+			t.Errorf("%s: expected dead letters %v, got %d", tst.description, tst.dead, len(dead))
+			continue
+		}
+		if tst.dead && (dead[0].hook.id != hook.id || dead[0].attempts != 3 || dead[0].reason != "503 Service Unavailable") {
+			t.Errorf("%s: unexpected dead letter %+v", tst.description, dead[0])
+		}
+	}
+}
+
+func TestWebhookPrivateDelivery(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	// The subscription is added directly, as if the name in its URL had resolved to a
+	// public address when it was subscribed and to the test server's loopback one since.
+	hooks := newWebhooks()
+	hooks.attempts, hooks.backoff = 1, time.Millisecond
+	hooks.subscribe(srv.URL, "s3cret", webhookEvents)
+	hooks.publish(event{id: 1, kind: "add", task: task{id: "task1", text: "task one"}})
+	hooks.inflight.Wait()
+
+	if len(rc.requests) != 0 {
+		t.Errorf("expected no deliveries to a loopback address, got %d", len(rc.requests))
+	}
+	if dead := hooks.deadLetters(); len(dead) != 1 || !strings.Contains(dead[0].reason, "not a public address") {
+		t.Errorf("expected the delivery to be refused, got dead letters %+v", dead)
+	}
+}
+
+func TestPublicIP(t *testing.T) {
+	tt := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"100.127.255.254", false},
+		{"100.128.0.1", true},
+		{"fd00::1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+	}
+
+	for _, tst := range tt {
+		if public := publicIP(net.ParseIP(tst.ip)); public != tst.public {
+			t.Errorf("%s: expected public %v, got %v", tst.ip, tst.public, public)
+		}
+	}
+}
+
+func TestWebhookDispatch(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	hooks := testWebhooks()
+	hooks.subscribe(srv.URL, "s3cret", webhookEvents)
+
+	store := newStore("task one")
+	stop := make(chan struct{})
+	defer close(stop)
+	go hooks.dispatch(store.feed, stop)
+	for watching := false; !watching; {
+		store.feed.mu.Lock()
+		watching = len(store.feed.watchers) > 0
+		store.feed.mu.Unlock()
+	}
+
+	store.add("task two", precondition{})
+	store.edit(1, "task 1", precondition{})
+
+	kinds := []string{}
+	for deadline := time.Now().Add(5 * time.Second); len(kinds) < 2 && time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		rc.mu.Lock()
+		kinds = kinds[:0]
+		for _, req := range rc.requests {
+			kinds = append(kinds, req.Header.Get("Taskd-Event")+" "+req.Header.Get("Taskd-Event-ID"))
+		}
+		rc.mu.Unlock()
+	}
+	if strings.Join(kinds, ",") != "add 2,edit 3" {
+		t.Errorf("expected the add and edit to be delivered in order, got %v", kinds)
+	}
+}
+
+func TestWebhookBacklog(t *testing.T) {
+	defer func(saved int) { maxQueuedDeliveries = saved }(maxQueuedDeliveries)
+	maxQueuedDeliveries = 3
+
+	received, release := make(chan string, 10), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received <- req.Header.Get("Taskd-Event-ID")
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	hooks := testWebhooks()
+	hooks.subscribe(srv.URL, "s3cret", webhookEvents)
+
+	// The first delivery holds the worker up, the next three fill the queue and the last two
+	// find it full.
+	hooks.publish(event{id: 1, kind: "add", task: task{id: "task1", text: "task one"}})
+	ids := []string{<-received}
+	for id := 2; id <= 6; id++ {
+		hooks.publish(event{id: id, kind: "add", task: task{id: fmt.Sprintf("task%d", id), text: "task"}})
+	}
+	close(release)
+	hooks.inflight.Wait()
+	close(received)
+
+	for id := range received {
+		ids = append(ids, id)
+	}
+	if strings.Join(ids, ",") != "1,2,3,4" {
+		t.Errorf("expected the queued deliveries to be made in order, got %v", ids)
+	}
+
+	dead := hooks.deadLetters()
+	if len(dead) != 2 || dead[0].event.id != 5 || dead[1].event.id != 6 || dead[0].reason != "too many deliveries waiting" {
+		t.Errorf("expected the deliveries beyond the queue to be dead letters, got %+v", dead)
+	}
+}
+
+func TestWebhookResources(t *testing.T) {
+	usecontext(t, context.WithValue(notasks(), "webhooks", testWebhooks()))
+	uselookup(t, map[string][]string{
+		"hooks.example.com":    {"93.184.216.34"},
+		"example.com":          {"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946"},
+		"internal.example.com": {"10.1.2.3"},
+		"split.example.com":    {"93.184.216.34", "127.0.0.1"},
+	})
+	r := router()
+
+	tt := []struct {
+		description string
+		method      string
+		url         string
+		payload     string
+		rc          int
+		code        string
+		hooks       []string
+	}{
+		{"no subscriptions", GET, "/webhooks", "", 200, "", []string{}},
+		{"subscribe", POST, "/webhooks", "url=http://hooks.example.com:9999/hook&secret=s3cret&events=complete", 201, "", []string{"hook1"}},
+		{"subscribe to everything", POST, "/webhooks", "url=https://example.com/hook&secret=s3cret", 201, "", []string{"hook1", "hook2"}},
+		{"missing url", POST, "/webhooks", "secret=s3cret", 400, errInvalidBody, nil},
+		{"relative url", POST, "/webhooks", "url=/hook&secret=s3cret", 400, errInvalidBody, nil},
+		{"other scheme", POST, "/webhooks", "url=file:///etc/passwd&secret=s3cret", 400, errInvalidBody, nil},
+		{"loopback address", POST, "/webhooks", "url=http://127.0.0.1:9999/hook&secret=s3cret", 400, errInvalidBody, nil},
+		{"loopback IPv6 address", POST, "/webhooks", "url=http://[::1]:9999/hook&secret=s3cret", 400, errInvalidBody, nil},
+		{"link-local address", POST, "/webhooks", "url=http://169.254.169.254/latest/meta-data&secret=s3cret", 400, errInvalidBody, nil},
+		{"private name", POST, "/webhooks", "url=https://internal.example.com/hook&secret=s3cret", 400, errInvalidBody, nil},
+		{"name with a private address", POST, "/webhooks", "url=https://split.example.com/hook&secret=s3cret", 400, errInvalidBody, nil},
+		{"unresolvable name", POST, "/webhooks", "url=https://nowhere.example.com/hook&secret=s3cret", 400, errInvalidBody, nil},
+		{"missing secret", POST, "/webhooks", "url=http://hooks.example.com:9999/hook", 400, errInvalidBody, nil},
+		{"unknown event", POST, "/webhooks", "url=http://hooks.example.com:9999/hook&secret=s3cret&events=delete", 400, errInvalidBody, nil},
+		{"list subscriptions", GET, "/webhooks", "", 200, "", []string{"hook1", "hook2"}},
+		{"unsubscribe", POST, "/webhooks/remove", "id=hook1", 200, "", []string{"hook2"}},
+		{"unsubscribe again", POST, "/webhooks/remove", "id=hook1", 404, errWebhookNotFound, nil},
+		{"dead letters", GET, "/webhooks/deadletters", "", 200, "", nil},
+	}
+
+	for _, tst := range tt {
+		req, err := http.NewRequest(tst.method, tst.url, strings.NewReader(tst.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tst.payload) > 0 {
+			req.Header.Set("Content-Type", formType)
+		}
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tst.rc {
+			t.Errorf("%s: expected status %d, got %d: %s", tst.description, tst.rc, w.Code, w.Body.String())
+			continue
+		}
+
+		ud, err := uber.Parse(w.Body)
+		if err != nil {
+			t.Errorf("%s: %v", tst.description, err)
+			continue
+		}
+		if errs := uber.Validate(ud); len(errs) > 0 {
+			t.Errorf("%s: invalid document: %v", tst.description, errs)
+		}
+
+		if len(tst.code) > 0 {
+			if e := uber.ErrorFromDoc(ud); e == nil || e.Code != tst.code {
+				t.Errorf("%s: expected error %s, got %v", tst.description, tst.code, e)
+			}
+			continue
+		}
+		if tst.hooks == nil {
+			continue
+		}
+
+		ids := []string{}
+		for _, d := range ud.FindByName("subscriptions") {
+			ids = append(ids, d.ID)
+			if len(d.FindByRel("unsubscribe")) != 1 || len(d.FindByName("secret")) != 0 {
+				t.Errorf("%s: unexpected subscription %+v", tst.description, d)
+			}
+		}
+		if strings.Join(ids, ",") != strings.Join(tst.hooks, ",") {
+			t.Errorf("%s: expected subscriptions %v, got %v", tst.description, tst.hooks, ids)
+		}
+	}
+}
@@ -11,61 +11,113 @@ var thisPage = function() {
   var g = {};
   g.msg = {};
   g.listUrl = '/tasks/';
+  g.uberType = 'application/vnd.uber+json';
+  g.formType = 'application/x-www-form-urlencoded';
 
   // prime the system
   function init() {
     makeRequest(g.listUrl,'list');
   }
 
+  // return the data elements of coll, at any depth, for which match is true
+  function findData(coll, match) {
+    var found, i, x;
+
+    found = [];
+    coll = coll || [];
+    for(i=0,x=coll.length;i<x;i++) {
+      if(match(coll[i])) {
+        found.push(coll[i]);
+      }
+      found = found.concat(findData(coll[i].data, match));
+    }
+    return found;
+  }
+
+  // convert an Uber XML element, as app.js sends, to its Uber JSON form
+  function fromXML(elm) {
+    var d, i, x, coll, attrs;
+
+    d = {};
+    attrs = ['id','name','url','action','model'];
+    for(i=0,x=attrs.length;i<x;i++) {
+      if(elm.getAttribute(attrs[i])!==null) {
+        d[attrs[i]] = elm.getAttribute(attrs[i]);
+      }
+    }
+    if(elm.getAttribute('rel')!==null) {
+      d.rel = elm.getAttribute('rel').split(' ');
+    }
+    d.template = (elm.getAttribute('templated')==='true');
+    d.data = [];
+    coll = elm.children;
+    for(i=0,x=coll.length;i<x;i++) {
+      d.data.push(fromXML(coll[i]));
+    }
+    if(x===0 && elm.childNodes.length>0) {
+      d.value = elm.childNodes[0].nodeValue;
+    }
+    return d;
+  }
+
+  // return the first rel of a data element
+  function relOf(data) {
+    return (data.rel && data.rel.length>0 ? data.rel[0] : '');
+  }
+
   /* parse the response */
   function showResponse() {
-    var elm, li, i, x, coll, item, data, task;
+    var elm, coll, i, x;
 
     // fill in the list
     elm = document.getElementById('data');
     if(elm) {
       elm.innerHTML = '';
 
-      coll = g.msg.getElementsByTagName('data');
+      coll = findData(g.msg.uber.data, function(d){return d.name==='tasks';});
       for(i=0,x=coll.length;i<x;i++) {
-        if(coll[i].getAttribute("name")==="tasks") {
-          li = document.createElement('li');
-          task = document.createElement('span');
-          task.className = 'task';
-
-          var item = coll[i].children;
-          for(j=0,y=item.length;j<y;j++) {
-            // handle transition elements
-            if(item[j].getAttribute("url")!==null) {
-              data = document.createElement('a');
-              data.href = item[j].getAttribute('url');
-              data.rel = item[j].getAttribute('rel');
-              data.title = item[j].getAttribute('rel');
-              if(item[j].getAttribute('model')!==null) {
-                data.setAttribute('model',item[j].getAttribute('model'));
-              }              
-              else {
-                data.setAttribute('model','');
-              }
-              data.setAttribute('action',item[j].getAttribute('action'));
-              data.innerHTML = 'X';
-            }
-            else {
-              // handle data elements
-              data = document.createElement('span');
-              data.className='item';
-              data.innerHTML = item[j].childNodes[0].nodeValue;
-            }
-            task.appendChild(data);
-          }                               
-          li.appendChild(task);
-          elm.appendChild(li);
-        }
+        elm.appendChild(showTask(coll[i]));
       }
     }
     showControls();
   }
 
+  // render a task item as a list element
+  function showTask(item) {
+    var li, task, data, i, x, coll;
+
+    li = document.createElement('li');
+    li.id = item.id;
+    task = document.createElement('span');
+    task.className = 'task';
+
+    coll = item.data || [];
+    for(i=0,x=coll.length;i<x;i++) {
+      // handle transition elements
+      if(coll[i].url) {
+        data = document.createElement('a');
+        data.href = coll[i].url;
+        data.rel = relOf(coll[i]);
+        data.title = relOf(coll[i]);
+        data.setAttribute('model',coll[i].model||'');
+        data.setAttribute('action',coll[i].action||'read');
+        data.innerHTML = 'X';
+        if(coll[i].action && coll[i].action!=='read') {
+          data.onclick = clickButton;
+        }
+      }
+      else {
+        // handle data elements
+        data = document.createElement('span');
+        data.className='item';
+        data.textContent = (coll[i].value!==undefined ? coll[i].value : '');
+      }
+      task.appendChild(data);
+    }
+    li.appendChild(task);
+    return li;
+  }
+
   // handle possible hypermedia controls
   function showControls() {
     var elm, inp, i, x, coll;
@@ -74,50 +126,107 @@ var thisPage = function() {
     elm = document.getElementById('actions');
     if(elm) {
       elm.innerHTML = '';
-      coll = g.msg.getElementsByTagName("data");
+      coll = findData(g.msg.uber.data, function(d){return d.name==='links';});
 
       for(i=0,x=coll.length;i<x;i++) {
-        if(coll[i].getAttribute('name')==="links") {
-          inp = document.createElement('input');
-          inp.type = "button";
-          inp.className = "button";
-          inp.id = coll[i].getAttribute('id');
-          
-          inp.setAttribute('action',coll[i].getAttribute('action'));
-          inp.setAttribute('href',coll[i].getAttribute('url'));
-          inp.setAttribute('model',coll[i].getAttribute('model'));
-          inp.value = coll[i].getAttribute('rel');
-          inp.onclick = clickButton;
-          
-          elm.appendChild(inp);
+        // the change feed is followed, not offered as a button
+        if(relOf(coll[i])==='events') {
+          watchEvents(coll[i].url);
+          continue;
         }
+        inp = document.createElement('input');
+        inp.type = "button";
+        inp.className = "button";
+        inp.id = coll[i].id;
+
+        inp.setAttribute('action',coll[i].action||'read');
+        inp.setAttribute('href',coll[i].url);
+        inp.setAttribute('model',coll[i].model||'');
+        inp.setAttribute('template',coll[i].template ? 'true' : '');
+        inp.value = relOf(coll[i]);
+        inp.onclick = clickButton;
+
+        elm.appendChild(inp);
+      }
+    }
+  }
+  // refresh the list whenever the server reports a change. Completions and moves shift
+  // the ids of other tasks, so the whole list is read again rather than patched.
+  function watchEvents(href) {
+    var kinds, i, x;
+
+    if(g.events || !window.EventSource) {
+      return;
+    }
+    g.events = new EventSource(href);
+    kinds = ['add','complete','edit','move','reset'];
+    for(i=0,x=kinds.length;i<x;i++) {
+      g.events.addEventListener(kinds[i], function(){makeRequest(g.listUrl, 'list');});
+    }
+  }
+
+  // fill in the {name} fields of a model, asking for those the server left open
+  function fillModel(model) {
+    var names, value;
+
+    names = {};
+    model = model.replace(/\{(\w+)\}/g, function(m, name) {
+      if(!(name in names)) {
+        value = prompt('Enter '+name+':');
+        if(value===null) {
+          return m;
+        }
+        names[name] = encodeURIComponent(value);
       }
+      return names[name];
+    });
+    if(/\{\w+\}/.test(model)) {
+      return null;
+    }
+    return model;
+  }
+
+  // expand the query of a URI template such as /tasks/search{?text}
+  function fillTemplate(href) {
+    var m, query;
+
+    m = /\{\?([\w,]+)\}/.exec(href);
+    if(!m) {
+      return href;
     }
+    query = fillModel(m[1].split(',').map(function(n){return n+'={'+n+'}';}).join('&'));
+    if(query===null) {
+      return null;
+    }
+    return href.replace(m[0], '?'+query);
   }
+
   function clickButton() {
-    var elm, inp, data;
+    var elm, href, body, query, context;
     elm = this;
-  
-    if(elm.getAttribute('data')) {
-      if(elm.getAttribute('dvalue')) {
-        inp = elm.getAttribute('dvalue');
+
+    href = elm.getAttribute('href');
+    context = elm.id || elm.rel;
+    if(elm.getAttribute('action')==='read') {
+      if(elm.getAttribute('template')) {
+        href = fillTemplate(href);
       }
-      else {
-        inp = prompt('Enter '+elm.getAttribute('data')+':');
+      else if(elm.getAttribute('model')) {
+        // the model of a read is its query string
+        query = fillModel(elm.getAttribute('model'));
+        href = (query===null ? null : href+query);
       }
-      if(inp) {
-        data = elm.getAttribute('data')+'='+encodeURIComponent(inp);
-        if(elm.getAttribute('method')==='get') {
-          makeRequest(elm.getAttribute('href')+'?'+data, elm.id);        
-        }
-        else {
-          makeRequest(elm.getAttribute('href'), elm.id, data)
-        }    
+      if(href) {
+        makeRequest(href, context);
       }
     }
     else {
-      makeRequest(elm.getAttribute('href'), elm.id);        
+      body = fillModel(elm.getAttribute('model')||'');
+      if(body!==null) {
+        makeRequest(href, context, body);
+      }
     }
+    return false;
   }
 
   // handle network request/response
@@ -129,12 +238,15 @@ var thisPage = function() {
 
       ajax.onreadystatechange = function(){processResponse(ajax, context);};
 
-      if(body) {
+      if(body!==undefined) {
         ajax.open('post',href,true);
+        ajax.setRequestHeader('Accept',g.uberType);
+        ajax.setRequestHeader('Content-Type',g.formType);
         ajax.send(body);
       }
       else {
         ajax.open('get',href,true);
+        ajax.setRequestHeader('Accept',g.uberType);
         ajax.send(null);
       }
     }
@@ -146,15 +258,17 @@ var thisPage = function() {
         switch(context) {
           case 'list':
           case 'search':
-            g.msg = ajax.responseXML.documentElement;
+            if(ajax.responseXML && ajax.responseXML.documentElement) {
+              g.msg = {uber: fromXML(ajax.responseXML.documentElement)};
+            }
+            else {
+              g.msg = JSON.parse(ajax.responseText);
+            }
             showResponse();
             break;
-          case 'add':
-          case 'complete':
-            makeRequest(g.listUrl, 'list');
-            break;
           default:
-            alert('unknown context:'+context);
+            // every other transition changes the list
+            makeRequest(g.listUrl, 'list');
             break;
         }
       }
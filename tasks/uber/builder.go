@@ -0,0 +1,137 @@
+package uber
+
+// DataBuilder incrementally constructs a Data element. Its methods return the builder so calls
+// can be chained, e.g.
+//
+//	uber.NewData().ID("search").Rel("search").URL("/tasks/search").Action(uber.ActionRead)
+type DataBuilder struct {
+	d Data
+}
+
+// NewData returns a builder for an empty Data element.
+func NewData() *DataBuilder {
+	return &DataBuilder{}
+}
+
+// ID sets the element's id.
+func (b *DataBuilder) ID(id string) *DataBuilder {
+	b.d.ID = id
+	return b
+}
+
+// Name sets the element's name.
+func (b *DataBuilder) Name(name string) *DataBuilder {
+	b.d.Name = name
+	return b
+}
+
+// Rel adds link relations to the element.
+func (b *DataBuilder) Rel(rels ...string) *DataBuilder {
+	b.d.Rel = append(b.d.Rel, rels...)
+	return b
+}
+
+// Label sets the element's label.
+func (b *DataBuilder) Label(label string) *DataBuilder {
+	b.d.Label = label
+	return b
+}
+
+// URL sets the element's url.
+func (b *DataBuilder) URL(url string) *DataBuilder {
+	b.d.URL = url
+	return b
+}
+
+// Template marks the element's url as a URI template.
+func (b *DataBuilder) Template(template bool) *DataBuilder {
+	b.d.Template = template
+	return b
+}
+
+// Action sets the element's action, one of the Action constants.
+func (b *DataBuilder) Action(action string) *DataBuilder {
+	b.d.Action = action
+	return b
+}
+
+// Transclude marks the element's url as content to be embedded in place.
+func (b *DataBuilder) Transclude(transclude bool) *DataBuilder {
+	b.d.Transclude = transclude
+	return b
+}
+
+// Model sets the element's model, the template for the arguments of its action.
+func (b *DataBuilder) Model(model string) *DataBuilder {
+	b.d.Model = model
+	return b
+}
+
+// Sending sets the media type the element's action sends.
+func (b *DataBuilder) Sending(mediaType string) *DataBuilder {
+	b.d.Sending = mediaType
+	return b
+}
+
+// Accepting adds media types the element's action accepts.
+func (b *DataBuilder) Accepting(mediaTypes ...string) *DataBuilder {
+	b.d.Accepting = append(b.d.Accepting, mediaTypes...)
+	return b
+}
+
+// Value sets the element's value.
+func (b *DataBuilder) Value(value string) *DataBuilder {
+	b.d.Value = value
+	return b
+}
+
+// Append adds child elements to the element.
+func (b *DataBuilder) Append(children ...*DataBuilder) *DataBuilder {
+	for _, c := range children {
+		b.d.Data = append(b.d.Data, c.Build())
+	}
+	return b
+}
+
+// Build returns the constructed Data element.
+func (b *DataBuilder) Build() Data {
+	d := b.d
+	d.Rel = append([]string(nil), b.d.Rel...)
+	d.Accepting = append([]string(nil), b.d.Accepting...)
+	d.Data = append([]Data(nil), b.d.Data...)
+	return d
+}
+
+// DocBuilder incrementally constructs an Uber document.
+type DocBuilder struct {
+	doc Doc
+}
+
+// NewDoc returns a builder for an empty document of the current Uber version.
+func NewDoc() *DocBuilder {
+	return &DocBuilder{Doc{Body{Version: Version}}}
+}
+
+// Data adds elements to the document's data section.
+func (b *DocBuilder) Data(ds ...*DataBuilder) *DocBuilder {
+	for _, d := range ds {
+		b.doc.Uber.Data = append(b.doc.Uber.Data, d.Build())
+	}
+	return b
+}
+
+// Error adds elements to the document's error section.
+func (b *DocBuilder) Error(ds ...*DataBuilder) *DocBuilder {
+	for _, d := range ds {
+		b.doc.Uber.Error = append(b.doc.Uber.Error, d.Build())
+	}
+	return b
+}
+
+// Build returns the constructed document.
+func (b *DocBuilder) Build() *Doc {
+	doc := b.doc
+	doc.Uber.Data = append([]Data(nil), b.doc.Uber.Data...)
+	doc.Uber.Error = append([]Data(nil), b.doc.Uber.Error...)
+	return &doc
+}
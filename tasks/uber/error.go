@@ -0,0 +1,106 @@
+package uber
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Error is a machine readable error carried in the error section of an Uber document. Code is
+// a stable identifier clients can branch on, Message is meant for people.
+type Error struct {
+	Code    string
+	Status  int
+	Message string
+	Fields  []FieldError
+	Help    string
+}
+
+// FieldError describes a problem with a single argument of a request.
+type FieldError struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Status > 0 {
+		return fmt.Sprintf("%s (%d): %s", e.Code, e.Status, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Data returns the elements that make up the error section of an Uber document describing
+// the error, e.g.
+//
+//	{ "name": "code", "value": "task_not_found" },
+//	{ "name": "status", "value": "404" },
+//	{ "name": "message", "value": "No such task" },
+//	{ "name": "field", "data": [ { "name": "field", "value": "id" }, ... ] },
+//	{ "rel": [ "help" ], "url": "/errors#task_not_found", "action": "read" }
+func (e *Error) Data() []*DataBuilder {
+	ds := []*DataBuilder{NewData().Name("code").Value(e.Code)}
+
+	if e.Status > 0 {
+		ds = append(ds, NewData().Name("status").Value(strconv.Itoa(e.Status)))
+	}
+
+	ds = append(ds, NewData().Name("message").Value(e.Message))
+
+	for _, f := range e.Fields {
+		ds = append(ds, NewData().Name("field").Append(
+			NewData().Name("field").Value(f.Field),
+			NewData().Name("code").Value(f.Code),
+			NewData().Name("message").Value(f.Message)))
+	}
+
+	if len(e.Help) > 0 {
+		ds = append(ds, NewData().Rel("help").URL(e.Help).Action(ActionRead))
+	}
+
+	return ds
+}
+
+// Doc returns an Uber document describing the error.
+func (e *Error) Doc() *Doc {
+	return NewDoc().Error(e.Data()...).Build()
+}
+
+// ErrorFromDoc extracts the error described by the document's error section. It returns nil
+// if the document has no error section.
+func ErrorFromDoc(ud *Doc) *Error {
+	if len(ud.Uber.Error) == 0 {
+		return nil
+	}
+
+	e := &Error{}
+	for _, d := range ud.Uber.Error {
+		switch {
+		case d.Name == "code":
+			e.Code = d.Value
+		case d.Name == "status":
+			e.Status, _ = strconv.Atoi(d.Value)
+		case d.Name == "message":
+			e.Message = d.Value
+		case d.Name == "field":
+			f := FieldError{}
+			for _, fd := range d.Data {
+				switch fd.Name {
+				case "field":
+					f.Field = fd.Value
+				case "code":
+					f.Code = fd.Value
+				case "message":
+					f.Message = fd.Value
+				}
+			}
+			e.Fields = append(e.Fields, f)
+		case d.HasRel("help"):
+			e.Help = d.URL
+		case len(e.Message) == 0 && len(d.Value) > 0:
+			// An error section that doesn't follow this layout still has something to say.
+			e.Code, e.Message = d.Name, d.Value
+		}
+	}
+
+	return e
+}
@@ -0,0 +1,52 @@
+package uber
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestErrorRoundTrip(t *testing.T) {
+	tt := []*Error{
+		{Code: "task_not_found", Status: 404, Message: "No such task", Help: "/errors#task_not_found"},
+		{Code: "invalid_body", Status: 400, Message: "Invalid add task body",
+			Fields: []FieldError{{Field: "text", Code: "required", Message: "text is required"}}},
+		{Code: "unknown", Message: "Something went wrong"},
+	}
+
+	for _, e := range tt {
+		bs, err := json.Marshal(e.Doc())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ud Doc
+		if err := json.Unmarshal(bs, &ud); err != nil {
+			t.Fatal(err)
+		}
+
+		if errs := Validate(&ud); len(errs) > 0 {
+			t.Errorf("%s: error document is not valid: %v", e.Code, errs)
+		}
+
+		if got := ErrorFromDoc(&ud); !reflect.DeepEqual(got, e) {
+			t.Errorf("%s: round trip mismatch:\nexpected %+v\ngot      %+v", e.Code, e, got)
+		}
+	}
+}
+
+func TestErrorFromDoc(t *testing.T) {
+	if e := ErrorFromDoc(NewDoc().Data(NewData().ID("tasks")).Build()); e != nil {
+		t.Errorf("document without an error section: expected nil, got %v", e)
+	}
+
+	legacy := NewDoc().Error(NewData().Name("ClientError").Rel("reason").Value("No such task")).Build()
+	e := ErrorFromDoc(legacy)
+	if e == nil || e.Code != "ClientError" || e.Message != "No such task" {
+		t.Errorf("unstructured error section: got %+v", e)
+	}
+
+	if s := (&Error{Code: "task_not_found", Status: 404, Message: "No such task"}).Error(); s != "task_not_found (404): No such task" {
+		t.Errorf("Error(): got %q", s)
+	}
+}
@@ -0,0 +1,175 @@
+// Package uber provides a model of the Uber hypermedia document format along with helpers
+// for building, searching and traversing Uber documents.
+package uber
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Version is the version of the Uber specification produced by this package.
+const Version = "1.0"
+
+// Values permitted in the action property of a Data element.
+const (
+	ActionAppend  = "append"
+	ActionPartial = "partial"
+	ActionRead    = "read"
+	ActionRemove  = "remove"
+	ActionReplace = "replace"
+)
+
+// Method returns the HTTP method that performs the given action. An empty action is treated
+// as read.
+func Method(action string) string {
+	switch action {
+	case ActionAppend:
+		return "POST"
+	case ActionPartial:
+		return "PATCH"
+	case ActionRemove:
+		return "DELETE"
+	case ActionReplace:
+		return "PUT"
+	default:
+		return "GET"
+	}
+}
+
+// Data represents the individual data elements of an Uber hypermedia document.
+type Data struct {
+	ID         string   `json:"id,omitempty"`
+	Name       string   `json:"name,omitempty"`
+	Rel        []string `json:"rel,omitempty"`
+	Label      string   `json:"label,omitempty"`
+	URL        string   `json:"url,omitempty"`
+	Template   bool     `json:"template,omitempty"`
+	Action     string   `json:"action,omitempty"`
+	Transclude bool     `json:"transclude,omitempty"`
+	Model      string   `json:"model,omitempty"`
+	Sending    string   `json:"sending,omitempty"`
+	Accepting  []string `json:"accepting,omitempty"`
+	Value      string   `json:"value,omitempty"`
+	Data       []Data   `json:"data,omitempty"`
+}
+
+// Body is the body of an Uber hypermedia document.
+type Body struct {
+	Version string `json:"version"`
+	Data    []Data `json:"data,omitempty"`
+	Error   []Data `json:"error,omitempty"`
+}
+
+// Doc represents an Uber hypermedia document.
+type Doc struct {
+	Uber Body `json:"uber"`
+}
+
+// HasRel reports whether rel is one of the element's link relations.
+func (d *Data) HasRel(rel string) bool {
+	for _, r := range d.Rel {
+		if r == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// FindByID returns the first element, in depth first order, below d whose id is id. It
+// returns nil if there is no such element.
+func (d *Data) FindByID(id string) *Data {
+	return findFirst(d.Data, func(e *Data) bool { return e.ID == id })
+}
+
+// FindByRel returns every element below d that has rel as one of its link relations.
+func (d *Data) FindByRel(rel string) []*Data {
+	return findAll(d.Data, func(e *Data) bool { return e.HasRel(rel) })
+}
+
+// FindByName returns every element below d whose name is name.
+func (d *Data) FindByName(name string) []*Data {
+	return findAll(d.Data, func(e *Data) bool { return e.Name == name })
+}
+
+// FindByID returns the first element, in depth first order, of the document's data section
+// whose id is id. It returns nil if there is no such element.
+func (ud *Doc) FindByID(id string) *Data {
+	return findFirst(ud.Uber.Data, func(e *Data) bool { return e.ID == id })
+}
+
+// FindByRel returns every element of the document's data section that has rel as one of its
+// link relations.
+func (ud *Doc) FindByRel(rel string) []*Data {
+	return findAll(ud.Uber.Data, func(e *Data) bool { return e.HasRel(rel) })
+}
+
+// FindByName returns every element of the document's data section whose name is name.
+func (ud *Doc) FindByName(name string) []*Data {
+	return findAll(ud.Uber.Data, func(e *Data) bool { return e.Name == name })
+}
+
+// SkipData is used as a return value from WalkFuncs to indicate that the children of the
+// element named in the call are to be skipped. It is not returned as an error by any function.
+var SkipData = errors.New("skip this data element")
+
+// WalkFunc is the type of the function called for each element visited by Walk. The path
+// argument locates the element within the document, e.g. "uber.data[1].data[0]".
+type WalkFunc func(path string, d *Data) error
+
+// Walk visits every element of the document, data section first and then error section, in
+// depth first order calling fn for each one. If fn returns SkipData the element's children are
+// not visited; any other error stops the walk and is returned by Walk.
+func (ud *Doc) Walk(fn WalkFunc) error {
+	if err := walk("uber.data", ud.Uber.Data, fn); err != nil {
+		return err
+	}
+	return walk("uber.error", ud.Uber.Error, fn)
+}
+
+// Walk visits the elements below d in depth first order calling fn for each one. Paths are
+// relative to d.
+func (d *Data) Walk(fn WalkFunc) error {
+	return walk("data", d.Data, fn)
+}
+
+func walk(prefix string, ds []Data, fn WalkFunc) error {
+	for i := range ds {
+		path := fmt.Sprintf("%s[%d]", prefix, i)
+		err := fn(path, &ds[i])
+		if err == SkipData {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := walk(path+".data", ds[i].Data, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findFirst(ds []Data, match func(*Data) bool) *Data {
+	var found *Data
+	walk("", ds, func(_ string, d *Data) error {
+		if match(d) {
+			found = d
+			return errStop
+		}
+		return nil
+	})
+	return found
+}
+
+func findAll(ds []Data, match func(*Data) bool) []*Data {
+	found := []*Data{}
+	walk("", ds, func(_ string, d *Data) error {
+		if match(d) {
+			found = append(found, d)
+		}
+		return nil
+	})
+	return found
+}
+
+var errStop = errors.New("stop walking")
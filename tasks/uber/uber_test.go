@@ -0,0 +1,146 @@
+package uber
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func sample() *Doc {
+	return NewDoc().
+		Data(
+			NewData().ID("links").Append(
+				NewData().ID("list").Name("links").Rel("collection").URL("/tasks/").Action(ActionRead),
+				NewData().ID("add").Name("links").Rel("add").URL("/tasks/").Action(ActionAppend).Model("text={text}")),
+			NewData().ID("tasks").Append(
+				NewData().ID("task1").Name("tasks").Rel("item").Append(
+					NewData().Rel("complete").URL("/tasks/complete/").Model("id=task1").Action(ActionAppend),
+					NewData().Name("text").Value("task one")),
+				NewData().ID("task2").Name("tasks").Rel("item").Append(
+					NewData().Rel("complete").URL("/tasks/complete/").Model("id=task2").Action(ActionAppend),
+					NewData().Name("text").Value("task two")))).
+		Error(NewData().Name("ClientError").Rel("reason").Value("oops")).
+		Build()
+}
+
+func TestBuilder(t *testing.T) {
+	expected := `{"uber":{"version":"1.0","data":[{"id":"links","data":[` +
+		`{"id":"list","name":"links","rel":["collection"],"url":"/tasks/","action":"read"},` +
+		`{"id":"add","name":"links","rel":["add"],"url":"/tasks/","action":"append","model":"text={text}"}]}]}}`
+
+	doc := NewDoc().
+		Data(NewData().ID("links").Append(
+			NewData().ID("list").Name("links").Rel("collection").URL("/tasks/").Action(ActionRead),
+			NewData().ID("add").Name("links").Rel("add").URL("/tasks/").Action(ActionAppend).Model("text={text}"))).
+		Build()
+
+	bs, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(bs) != expected {
+		t.Errorf("Builder mismatch:\nexpected %s\ngot      %s", expected, string(bs))
+	}
+}
+
+func TestBuilderIsolation(t *testing.T) {
+	b := NewData().ID("list").Rel("collection")
+	d := b.Build()
+	b.Rel("item")
+
+	if !reflect.DeepEqual(d.Rel, []string{"collection"}) {
+		t.Errorf("built element changed by later builder calls: %v", d.Rel)
+	}
+}
+
+func TestFindByID(t *testing.T) {
+	tt := []struct {
+		id    string
+		found bool
+	}{
+		{"links", true},
+		{"add", true},
+		{"task2", true},
+		{"task3", false},
+	}
+
+	doc := sample()
+	for _, tst := range tt {
+		d := doc.FindByID(tst.id)
+		if (d != nil) != tst.found {
+			t.Errorf("FindByID(%q): expected found %v, got %v", tst.id, tst.found, d != nil)
+			continue
+		}
+		if d != nil && d.ID != tst.id {
+			t.Errorf("FindByID(%q): got element with id %q", tst.id, d.ID)
+		}
+	}
+
+	doc.FindByID("tasks").Data = nil
+	if doc.FindByID("task1") != nil {
+		t.Errorf("FindByID did not return a pointer into the document")
+	}
+}
+
+func TestFindByRelAndName(t *testing.T) {
+	doc := sample()
+
+	if n := len(doc.FindByRel("complete")); n != 2 {
+		t.Errorf("FindByRel(complete): expected 2 elements, got %d", n)
+	}
+	if n := len(doc.FindByRel("missing")); n != 0 {
+		t.Errorf("FindByRel(missing): expected 0 elements, got %d", n)
+	}
+	if n := len(doc.FindByName("tasks")); n != 2 {
+		t.Errorf("FindByName(tasks): expected 2 elements, got %d", n)
+	}
+	if n := len(doc.FindByID("task1").FindByName("text")); n != 1 {
+		t.Errorf("FindByName(text) below task1: expected 1 element, got %d", n)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	expected := []string{
+		"uber.data[0]",
+		"uber.data[0].data[0]",
+		"uber.data[0].data[1]",
+		"uber.data[1]",
+		"uber.data[1].data[0]",
+		"uber.data[1].data[1]",
+		"uber.error[0]",
+	}
+
+	paths := []string{}
+	err := sample().Walk(func(path string, d *Data) error {
+		paths = append(paths, path)
+		if d.HasRel("item") {
+			return SkipData
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Walk mismatch:\nexpected %v\ngot      %v", expected, paths)
+	}
+}
+
+func TestMethod(t *testing.T) {
+	tt := map[string]string{
+		"":            "GET",
+		ActionRead:    "GET",
+		ActionAppend:  "POST",
+		ActionPartial: "PATCH",
+		ActionRemove:  "DELETE",
+		ActionReplace: "PUT",
+	}
+
+	for action, method := range tt {
+		if m := Method(action); m != method {
+			t.Errorf("Method(%q): expected %s, got %s", action, method, m)
+		}
+	}
+}
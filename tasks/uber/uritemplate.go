@@ -0,0 +1,324 @@
+package uber
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// URITemplate is a parsed RFC 6570 URI template. Templates are expanded with string values
+// so level 4 prefix modifiers are honored while explode modifiers have no effect.
+type URITemplate struct {
+	raw   string
+	parts []tplPart
+}
+
+// tplPart is either a literal run of the template or an expression.
+type tplPart struct {
+	literal string
+	op      *tplOperator
+	vars    []tplVar
+}
+
+type tplVar struct {
+	name    string
+	prefix  int
+	explode bool
+}
+
+// tplOperator describes how the variables of an expression are expanded (RFC 6570 appendix A).
+type tplOperator struct {
+	first    string
+	sep      string
+	named    bool
+	ifemp    string
+	reserved bool
+}
+
+var tplOperators = map[byte]*tplOperator{
+	0:   {"", ",", false, "", false},
+	'+': {"", ",", false, "", true},
+	'.': {".", ".", false, "", false},
+	'/': {"/", "/", false, "", false},
+	';': {";", ";", true, "", false},
+	'?': {"?", "&", true, "=", false},
+	'&': {"&", "&", true, "=", false},
+	'#': {"#", ",", false, "", true},
+}
+
+var tplVarname = regexp.MustCompile(`^([A-Za-z0-9_]|%[0-9A-Fa-f]{2})(\.?([A-Za-z0-9_]|%[0-9A-Fa-f]{2}))*$`)
+
+// ParseTemplate parses an RFC 6570 URI template.
+func ParseTemplate(s string) (*URITemplate, error) {
+	t := &URITemplate{raw: s}
+
+	for len(s) > 0 {
+		open := strings.Index(s, "{")
+		if open < 0 {
+			if strings.Contains(s, "}") {
+				return nil, fmt.Errorf("uber: unmatched '}' in URI template %q", t.raw)
+			}
+			t.parts = append(t.parts, tplPart{literal: s})
+			break
+		}
+
+		if open > 0 {
+			if strings.Contains(s[:open], "}") {
+				return nil, fmt.Errorf("uber: unmatched '}' in URI template %q", t.raw)
+			}
+			t.parts = append(t.parts, tplPart{literal: s[:open]})
+		}
+
+		end := strings.Index(s[open:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("uber: unterminated expression in URI template %q", t.raw)
+		}
+
+		p, err := parseExpression(s[open+1 : open+end])
+		if err != nil {
+			return nil, fmt.Errorf("uber: %v in URI template %q", err, t.raw)
+		}
+		t.parts = append(t.parts, p)
+		s = s[open+end+1:]
+	}
+
+	return t, nil
+}
+
+func parseExpression(expr string) (tplPart, error) {
+	if len(expr) == 0 {
+		return tplPart{}, fmt.Errorf("empty expression")
+	}
+
+	p := tplPart{op: tplOperators[0]}
+	if op, ok := tplOperators[expr[0]]; ok && expr[0] != 0 {
+		p.op = op
+		expr = expr[1:]
+	}
+
+	for _, spec := range strings.Split(expr, ",") {
+		v := tplVar{name: spec}
+		switch {
+		case strings.HasSuffix(spec, "*"):
+			v.name, v.explode = spec[:len(spec)-1], true
+		case strings.Contains(spec, ":"):
+			i := strings.Index(spec, ":")
+			n, err := strconv.Atoi(spec[i+1:])
+			if err != nil || n <= 0 || n >= 10000 {
+				return tplPart{}, fmt.Errorf("invalid prefix modifier %q", spec)
+			}
+			v.name, v.prefix = spec[:i], n
+		}
+
+		if !tplVarname.MatchString(v.name) {
+			return tplPart{}, fmt.Errorf("invalid variable name %q", v.name)
+		}
+		p.vars = append(p.vars, v)
+	}
+
+	return p, nil
+}
+
+// MustParseTemplate is like ParseTemplate but panics if the template cannot be parsed.
+func MustParseTemplate(s string) *URITemplate {
+	t, err := ParseTemplate(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// String returns the template's source text.
+func (t *URITemplate) String() string {
+	return t.raw
+}
+
+// Variables returns the names of the template's variables in the order they appear.
+func (t *URITemplate) Variables() []string {
+	names := []string{}
+	for _, p := range t.parts {
+		for _, v := range p.vars {
+			names = append(names, v.name)
+		}
+	}
+	return names
+}
+
+// Expand fills the template's variables from values. Variables without a value are omitted
+// from the expansion as RFC 6570 requires.
+func (t *URITemplate) Expand(values map[string]string) string {
+	buf := bytes.NewBuffer([]byte{})
+
+	for _, p := range t.parts {
+		if p.op == nil {
+			buf.WriteString(p.literal)
+			continue
+		}
+
+		first := true
+		for _, v := range p.vars {
+			value, ok := values[v.name]
+			if !ok {
+				continue
+			}
+
+			if first {
+				buf.WriteString(p.op.first)
+				first = false
+			} else {
+				buf.WriteString(p.op.sep)
+			}
+
+			if v.prefix > 0 {
+				if r := []rune(value); len(r) > v.prefix {
+					value = string(r[:v.prefix])
+				}
+			}
+
+			if p.op.named {
+				buf.WriteString(v.name)
+				if len(value) == 0 {
+					buf.WriteString(p.op.ifemp)
+					continue
+				}
+				buf.WriteString("=")
+			}
+			buf.WriteString(pctEncode(value, p.op.reserved))
+		}
+	}
+
+	return buf.String()
+}
+
+// Match reports whether uri could be an expansion of the template and, if it could, returns
+// the values of the variables it contains. Query expressions match the uri's query string
+// regardless of the order of its parameters.
+func (t *URITemplate) Match(uri string) (map[string]string, bool) {
+	values := map[string]string{}
+
+	var (
+		query     bool
+		queryvars []tplVar
+	)
+	pattern := bytes.NewBufferString("^")
+	groups := []tplPart{}
+
+	for _, p := range t.parts {
+		switch {
+		case p.op == nil:
+			pattern.WriteString(regexp.QuoteMeta(p.literal))
+		case p.op == tplOperators['?'] || p.op == tplOperators['&']:
+			query = true
+			queryvars = append(queryvars, p.vars...)
+		case p.op == tplOperators[0]:
+			pattern.WriteString(`([^/?#]*)`)
+			groups = append(groups, p)
+		case p.op == tplOperators['+']:
+			pattern.WriteString(`([^?#]*)`)
+			groups = append(groups, p)
+		case p.op == tplOperators['#']:
+			pattern.WriteString(`(?:#(.*))?`)
+			groups = append(groups, p)
+		default:
+			pattern.WriteString(`((?:` + regexp.QuoteMeta(p.op.first) + `[^/?#` + regexp.QuoteMeta(p.op.sep) + `]*)*)`)
+			groups = append(groups, p)
+		}
+	}
+
+	path, rawquery := uri, ""
+	if query {
+		if i := strings.Index(uri, "?"); i >= 0 {
+			path, rawquery = uri[:i], uri[i+1:]
+		}
+		if i := strings.Index(rawquery, "#"); i >= 0 {
+			rawquery = rawquery[:i]
+		}
+		pattern.WriteString(`(?:#.*)?`)
+	}
+	pattern.WriteString("$")
+
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return nil, false
+	}
+
+	sm := re.FindStringSubmatch(path)
+	if sm == nil {
+		return nil, false
+	}
+
+	for i, p := range groups {
+		if len(sm[i+1]) == 0 {
+			continue
+		}
+
+		s := strings.TrimPrefix(sm[i+1], p.op.first)
+		for j, v := range strings.Split(s, p.op.sep) {
+			if p.op.named {
+				kv := strings.SplitN(v, "=", 2)
+				if len(kv) == 2 {
+					values[kv[0]], _ = url.PathUnescape(kv[1])
+				} else {
+					values[kv[0]] = ""
+				}
+				continue
+			}
+
+			if j < len(p.vars) {
+				values[p.vars[j].name], _ = url.PathUnescape(v)
+			}
+		}
+	}
+
+	if len(rawquery) > 0 {
+		q, err := url.ParseQuery(rawquery)
+		if err != nil {
+			return nil, false
+		}
+		for _, v := range queryvars {
+			if vs, ok := q[v.name]; ok && len(vs) > 0 {
+				values[v.name] = vs[0]
+			}
+		}
+	}
+
+	return values, true
+}
+
+const hexdigits = "0123456789ABCDEF"
+
+// pctEncode percent encodes s leaving unreserved characters, and when reserved is true
+// reserved characters and existing percent encoded triplets, untouched.
+func pctEncode(s string, reserved bool) string {
+	buf := bytes.NewBuffer([]byte{})
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case isUnreserved(c):
+			buf.WriteByte(c)
+		case reserved && strings.IndexByte(":/?#[]@!$&'()*+,;=", c) >= 0:
+			buf.WriteByte(c)
+		case reserved && c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			buf.WriteString(s[i : i+3])
+			i += 2
+		default:
+			buf.WriteByte('%')
+			buf.WriteByte(hexdigits[c>>4])
+			buf.WriteByte(hexdigits[c&0xf])
+		}
+	}
+
+	return buf.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'A' <= c && c <= 'F' || 'a' <= c && c <= 'f'
+}
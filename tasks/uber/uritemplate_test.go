@@ -0,0 +1,111 @@
+package uber
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Examples from RFC 6570 section 3.2, restricted to string values.
+var tplvalues = map[string]string{
+	"var":   "value",
+	"hello": "Hello World!",
+	"path":  "/foo/bar",
+	"empty": "",
+	"x":     "1024",
+	"y":     "768",
+	"text":  "task one",
+}
+
+func TestExpand(t *testing.T) {
+	tt := []struct {
+		template string
+		expected string
+	}{
+		{"{var}", "value"},
+		{"{hello}", "Hello%20World%21"},
+		{"{+hello}", "Hello%20World!"},
+		{"{+path}/here", "/foo/bar/here"},
+		{"{#path,x}/here", "#/foo/bar,1024/here"},
+		{"map?{x,y}", "map?1024,768"},
+		{"{x,hello,y}", "1024,Hello%20World%21,768"},
+		{"X{.var}", "X.value"},
+		{"X{.x,y}", "X.1024.768"},
+		{"{/var,x}/here", "/value/1024/here"},
+		{"{;x,y,empty}", ";x=1024;y=768;empty"},
+		{"{?x,y,empty}", "?x=1024&y=768&empty="},
+		{"?fixed=yes{&x}", "?fixed=yes&x=1024"},
+		{"{var:3}", "val"},
+		{"{?undef}", ""},
+		{"/tasks/search{?text}", "/tasks/search?text=task%20one"},
+	}
+
+	for _, tst := range tt {
+		tpl, err := ParseTemplate(tst.template)
+		if err != nil {
+			t.Errorf("ParseTemplate(%q): %v", tst.template, err)
+			continue
+		}
+
+		if s := tpl.Expand(tplvalues); s != tst.expected {
+			t.Errorf("Expand(%q): expected %q, got %q", tst.template, tst.expected, s)
+		}
+	}
+}
+
+func TestParseTemplateErrors(t *testing.T) {
+	for _, s := range []string{"{", "}", "/a}b{c}", "{}", "{bad-name}", "{var:0}", "{var:x}"} {
+		if _, err := ParseTemplate(s); err == nil {
+			t.Errorf("ParseTemplate(%q): expected an error", s)
+		}
+	}
+}
+
+func TestVariables(t *testing.T) {
+	tpl := MustParseTemplate("/tasks/{id}{?text,limit}")
+	if vs := tpl.Variables(); !reflect.DeepEqual(vs, []string{"id", "text", "limit"}) {
+		t.Errorf("Variables: got %v", vs)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tt := []struct {
+		template string
+		uri      string
+		ok       bool
+		values   map[string]string
+	}{
+		{"/tasks/search{?text}", "/tasks/search?text=task+one", true, map[string]string{"text": "task one"}},
+		{"/tasks/search{?text}", "/tasks/search?text=task%20one&other=x", true, map[string]string{"text": "task one"}},
+		{"/tasks/search{?text}", "/tasks/search", true, map[string]string{}},
+		{"/tasks/search{?text}", "/tasks?text=task", false, nil},
+		{"/tasks/{id}", "/tasks/task1", true, map[string]string{"id": "task1"}},
+		{"/tasks/{id}", "/tasks/task1/more", false, nil},
+		{"/tasks{/id,view}", "/tasks/task1/full", true, map[string]string{"id": "task1", "view": "full"}},
+		{"/files{+path}", "/files/a/b%20c", true, map[string]string{"path": "/a/b c"}},
+		{"/map{;x,y}", "/map;x=1024;y=768", true, map[string]string{"x": "1024", "y": "768"}},
+		{"/search{?q}{&page}", "/search?page=2&q=eggs", true, map[string]string{"q": "eggs", "page": "2"}},
+	}
+
+	for _, tst := range tt {
+		values, ok := MustParseTemplate(tst.template).Match(tst.uri)
+		if ok != tst.ok {
+			t.Errorf("Match(%q, %q): expected ok %v, got %v", tst.template, tst.uri, tst.ok, ok)
+			continue
+		}
+
+		if ok && !reflect.DeepEqual(values, tst.values) {
+			t.Errorf("Match(%q, %q): expected %v, got %v", tst.template, tst.uri, tst.values, values)
+		}
+	}
+}
+
+func TestExpandMatchRoundTrip(t *testing.T) {
+	values := map[string]string{"id": "task 1/2", "text": "eggs & milk"}
+	for _, s := range []string{"/tasks/{id}{?text}", "/tasks{/id}{?text}"} {
+		tpl := MustParseTemplate(s)
+		got, ok := tpl.Match(tpl.Expand(values))
+		if !ok || !reflect.DeepEqual(got, values) {
+			t.Errorf("round trip of %q through %q: got %v, %v", values, s, got, ok)
+		}
+	}
+}
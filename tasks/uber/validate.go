@@ -0,0 +1,111 @@
+package uber
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Parse reads a JSON encoded Uber document from r.
+func Parse(r io.Reader) (*Doc, error) {
+	var raw struct {
+		Uber *Body `json:"uber"`
+	}
+
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("uber: cannot parse document: %v", err)
+	}
+
+	if raw.Uber == nil {
+		return nil, errors.New("uber: document has no uber property")
+	}
+
+	return &Doc{Uber: *raw.Uber}, nil
+}
+
+// ValidationError describes a single violation of the Uber specification. Path locates the
+// offending node, e.g. "uber.data[1].data[0]".
+type ValidationError struct {
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// Validate checks ud against the rules of the Uber 1.0 specification and returns every
+// violation it finds. A valid document yields an empty slice.
+func Validate(ud *Doc) []ValidationError {
+	errs := []ValidationError{}
+	report := func(path, format string, args ...interface{}) {
+		errs = append(errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch ud.Uber.Version {
+	case "":
+		report("uber.version", "version is missing")
+	case Version:
+	default:
+		report("uber.version", "unsupported version %q", ud.Uber.Version)
+	}
+
+	ids := map[string]string{}
+	ud.Walk(func(path string, d *Data) error {
+		if len(d.ID) > 0 {
+			if first, ok := ids[d.ID]; ok {
+				report(path, "duplicate id %q, first used at %s", d.ID, first)
+			} else {
+				ids[d.ID] = path
+			}
+		}
+
+		for _, rel := range d.Rel {
+			if len(strings.TrimSpace(rel)) == 0 {
+				report(path, "empty rel value")
+			}
+		}
+
+		switch d.Action {
+		case "", ActionAppend, ActionPartial, ActionRead, ActionRemove, ActionReplace:
+		default:
+			report(path, "invalid action %q", d.Action)
+		}
+
+		if len(d.URL) == 0 {
+			if d.Template {
+				report(path, "template is set but there is no url")
+			}
+			if d.Transclude {
+				report(path, "transclude is set but there is no url")
+			}
+			if len(d.Action) > 0 {
+				report(path, "action %q has no url", d.Action)
+			}
+			if len(d.Model) > 0 {
+				report(path, "model has no url")
+			}
+		} else {
+			expressions := strings.Contains(d.URL, "{")
+			if d.Template && !expressions {
+				report(path, "template is set but url %q has no template expressions", d.URL)
+			}
+			if !d.Template && expressions {
+				report(path, "url %q contains template expressions but template is not set", d.URL)
+			}
+		}
+
+		return nil
+	})
+
+	for i := range ud.Uber.Error {
+		e := &ud.Uber.Error[i]
+		if len(e.Value) == 0 && len(e.Data) == 0 && len(e.URL) == 0 {
+			report(fmt.Sprintf("uber.error[%d]", i), "error element has no value, data or url")
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,75 @@
+package uber
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tt := []struct {
+		description string
+		doc         string
+		ok          bool
+	}{
+		{"minimal document", `{"uber":{"version":"1.0"}}`, true},
+		{"document with data", `{"uber":{"version":"1.0","data":[{"id":"a","value":"x"}]}}`, true},
+		{"not an uber document", `{"hal":{}}`, false},
+		{"malformed json", `{"uber":`, false},
+	}
+
+	for _, tst := range tt {
+		_, err := Parse(strings.NewReader(tst.doc))
+		if (err == nil) != tst.ok {
+			t.Errorf("%s: expected ok %v, got error %v", tst.description, tst.ok, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tt := []struct {
+		description string
+		doc         string
+		paths       []string
+	}{
+		{"valid document", `{"uber":{"version":"1.0","data":[{"id":"links","data":[
+			{"id":"search","rel":["search"],"url":"/tasks/search{?text}","template":true,"action":"read"},
+			{"id":"add","rel":["add"],"url":"/tasks/","action":"append","model":"text={text}"}]}]}}`,
+			[]string{}},
+		{"missing version", `{"uber":{"data":[{"value":"x"}]}}`, []string{"uber.version"}},
+		{"unsupported version", `{"uber":{"version":"2.0"}}`, []string{"uber.version"}},
+		{"invalid action", `{"uber":{"version":"1.0","data":[{"data":[{"url":"/x","action":"get"}]}]}}`,
+			[]string{"uber.data[0].data[0]"}},
+		{"action without url", `{"uber":{"version":"1.0","data":[{"action":"read"}]}}`, []string{"uber.data[0]"}},
+		{"template without expressions", `{"uber":{"version":"1.0","data":[{"url":"/x","template":true}]}}`,
+			[]string{"uber.data[0]"}},
+		{"expressions without template", `{"uber":{"version":"1.0","data":[{"url":"/x/{id}"}]}}`,
+			[]string{"uber.data[0]"}},
+		{"transclude without url", `{"uber":{"version":"1.0","data":[{"transclude":true}]}}`, []string{"uber.data[0]"}},
+		{"duplicate ids", `{"uber":{"version":"1.0","data":[{"id":"a"},{"data":[{"id":"a"}]}]}}`,
+			[]string{"uber.data[1].data[0]"}},
+		{"empty rel", `{"uber":{"version":"1.0","data":[{"rel":[""]}]}}`, []string{"uber.data[0]"}},
+		{"empty error", `{"uber":{"version":"1.0","error":[{"name":"ServerError"}]}}`, []string{"uber.error[0]"}},
+		{"valid error", `{"uber":{"version":"1.0","error":[{"name":"ServerError","rel":["reason"],"value":"oops"}]}}`,
+			[]string{}},
+		{"error with help link", `{"uber":{"version":"1.0","error":[{"name":"code","value":"oops"},{"rel":["help"],"url":"/errors","action":"read"}]}}`,
+			[]string{}},
+	}
+
+	for _, tst := range tt {
+		ud, err := Parse(strings.NewReader(tst.doc))
+		if err != nil {
+			t.Errorf("%s: %v", tst.description, err)
+			continue
+		}
+
+		paths := []string{}
+		for _, e := range Validate(ud) {
+			paths = append(paths, e.Path)
+		}
+
+		if !reflect.DeepEqual(paths, tst.paths) {
+			t.Errorf("%s: expected violations at %v, got %v", tst.description, tst.paths, Validate(ud))
+		}
+	}
+}